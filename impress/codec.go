@@ -0,0 +1,121 @@
+package impress
+
+import (
+	bufio "bufio"
+	io "io"
+	strings "strings"
+	sync "sync"
+)
+
+// Slide previews are sent as a single base64 line, so this has to fit the largest of them
+const DEFAULT_MAX_MESSAGE_SIZE = 8 * 1024 * 1024
+
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "impress protocol: " + e.Reason
+}
+
+var (
+	ErrMessageTooLarge = &ProtocolError{Reason: "message exceeds the maximum size"}
+	ErrEmptyMessage    = &ProtocolError{Reason: "message has no command"}
+	ErrInvalidLine     = &ProtocolError{Reason: "message line is empty or contains a line break"}
+)
+
+// Decoder reads blank line terminated messages from a connection. It must live as long as the connection,
+// since bytes buffered past the end of a message already belong to the next one
+type Decoder struct {
+	reader  *bufio.Reader
+	maxSize int
+}
+
+// Encoder writes blank line terminated messages to a connection
+type Encoder struct {
+	writer *bufio.Writer
+	mu     sync.Mutex
+}
+
+func NewDecoder(r io.Reader, maxSize int) *Decoder {
+	return &Decoder{reader: bufio.NewReader(r), maxSize: maxSize}
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{writer: bufio.NewWriter(w)}
+}
+
+// Decode returns the lines of the next message. Protocol errors leave the decoder positioned at the following message
+func (d *Decoder) Decode() ([]string, error) {
+	message := make([]string, 0)
+	size := 0
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+
+		size += len(line)
+		if size <= d.maxSize {
+			message = append(message, line)
+		}
+	}
+
+	if size > d.maxSize {
+		return nil, ErrMessageTooLarge
+	}
+	if len(message) == 0 {
+		return nil, ErrEmptyMessage
+	}
+	return message, nil
+}
+
+func (d *Decoder) DecodeMessage() (ProtocolMessage, error) {
+	lines, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return ParseMessage(lines)
+}
+
+func (d *Decoder) readLine() (string, error) {
+	var builder strings.Builder
+	for {
+		bytes, isPrefix, err := d.reader.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if builder.Len() <= d.maxSize {
+			builder.Write(bytes)
+		}
+		if !isPrefix {
+			return builder.String(), nil
+		}
+	}
+}
+
+func (e *Encoder) Encode(message []string) error {
+	if len(message) == 0 {
+		return ErrEmptyMessage
+	}
+	for _, line := range message {
+		if line == "" || strings.ContainsAny(line, "\r\n") {
+			return ErrInvalidLine
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, err := e.writer.WriteString(strings.Join(message, "\n") + "\n\n"); err != nil {
+		return err
+	}
+	return e.writer.Flush()
+}
+
+func (e *Encoder) EncodeMessage(message ProtocolMessage) error {
+	return e.Encode(message.Lines())
+}
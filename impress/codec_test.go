@@ -0,0 +1,92 @@
+package impress
+
+import (
+	bytes "bytes"
+	io "io"
+	reflect "reflect"
+	strings "strings"
+	testing "testing"
+)
+
+func TestDecoderKeepsBufferedMessages(t *testing.T) {
+	decoder := NewDecoder(strings.NewReader("slide_preview\n1\naGVsbG8=\n\nslide_updated\n1\n\n"), DEFAULT_MAX_MESSAGE_SIZE)
+
+	expected := [][]string{
+		{SLIDE_PREVIEW, "1", "aGVsbG8="},
+		{SLIDE_UPDATED, "1"},
+	}
+	for _, want := range expected {
+		message, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if !reflect.DeepEqual(message, want) {
+			t.Errorf("decoded %v, expected %v", message, want)
+		}
+	}
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("expected EOF after the last message, got %v", err)
+	}
+}
+
+func TestDecoderMultiLinePayloads(t *testing.T) {
+	preview := strings.Repeat("A", 10000)
+	decoder := NewDecoder(strings.NewReader("slide_notes\n2\n<p>first</p>\r\n<p>second</p>\n\n"+SLIDE_PREVIEW+"\n2\n"+preview+"\n\n"), DEFAULT_MAX_MESSAGE_SIZE)
+
+	message, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if want := []string{SLIDE_NOTES, "2", "<p>first</p>", "<p>second</p>"}; !reflect.DeepEqual(message, want) {
+		t.Errorf("decoded %v, expected %v", message, want)
+	}
+
+	message, err = decoder.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(message) != 3 || message[2] != preview {
+		t.Errorf("long preview line was not decoded as a single line")
+	}
+}
+
+func TestDecoderMaximumSize(t *testing.T) {
+	decoder := NewDecoder(strings.NewReader("slide_preview\n1\n"+strings.Repeat("A", 100)+"\n\nslide_updated\n1\n\n"), 64)
+
+	if _, err := decoder.Decode(); err != ErrMessageTooLarge {
+		t.Fatalf("expected %v, got %v", ErrMessageTooLarge, err)
+	}
+	message, err := decoder.Decode()
+	if err != nil || !reflect.DeepEqual(message, []string{SLIDE_UPDATED, "1"}) {
+		t.Errorf("decoder did not recover after an oversized message: %v %v", message, err)
+	}
+}
+
+func TestDecoderEmptyMessage(t *testing.T) {
+	decoder := NewDecoder(strings.NewReader("\n"+SLIDE_SHOW_FINISHED+"\n\n"), DEFAULT_MAX_MESSAGE_SIZE)
+
+	if _, err := decoder.Decode(); err != ErrEmptyMessage {
+		t.Fatalf("expected %v, got %v", ErrEmptyMessage, err)
+	}
+	if _, err := decoder.Decode(); err != nil {
+		t.Errorf("Decode: %v", err)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := NewEncoder(buffer)
+
+	if err := encoder.Encode([]string{GO_TO_SLIDE, "3"}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if buffer.String() != "goto_slide\n3\n\n" {
+		t.Errorf("encoded %q", buffer.String())
+	}
+	if err := encoder.Encode([]string{PAIR_WITH_SERVER, "Remote\n", "1234"}); err != ErrInvalidLine {
+		t.Errorf("expected %v, got %v", ErrInvalidLine, err)
+	}
+	if err := encoder.Encode([]string{}); err != ErrEmptyMessage {
+		t.Errorf("expected %v, got %v", ErrEmptyMessage, err)
+	}
+}
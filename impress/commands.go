@@ -0,0 +1,81 @@
+package impress
+
+import (
+	json "encoding/json"
+	time "time"
+)
+
+// Time a command waits for Impress to report the slide it moved to
+var CommandTimeout = 2 * time.Second
+
+// ErrOutOfRange is returned by Execute for a slide that doesn't exist, with the number of slides as detail
+var ErrOutOfRange = NewError(ERR_INVALID_INDEX, "index value out of range")
+
+// DecodeCommand validates a command given outside of the websocket, the same way as the requests of controllers
+func DecodeCommand(fields map[string]string) (Message, error) {
+	body, _ := json.Marshal(fields)
+	return decodeRequest(body)
+}
+
+// Execute queues a command for Impress on behalf of the owner, through the same queue as the controllers,
+// and returns the status of the slideshow it resulted in. Commands moving to another slide wait for Impress to report it
+func (impr *ImpressClient) Execute(request Message) (SlideShowStatus, error) {
+	command, ok := request.(ProtocolMessage)
+	if !ok || isPointerRequest(command) {
+		return SlideShowStatus{}, NewError(ERR_NOT_SUPPORTED, "Command can't be executed remotely")
+	}
+	if session := impr.GetStats().Session; session == SESSION_CONNECTING || session == SESSION_PAIRING {
+		return SlideShowStatus{}, ErrNotReady
+	}
+	if goTo, ok := command.(GoToSlide); ok && impr.isOutOfBounds(goTo) {
+		return SlideShowStatus{}, ErrOutOfRange.WithDetail("totalSlides", impr.GetStats().Status.TotalSlides)
+	}
+
+	impr.mu.Lock()
+	if impr.isTerminated {
+		impr.mu.Unlock()
+		return SlideShowStatus{}, ErrNotRunning
+	}
+	changed := impr.statusChanged
+	moves := impr.movesLocked(command)
+	impr.mu.Unlock()
+
+	select {
+	case impr.requests <- impressRequest{command: command}:
+	case <-impr.shutdown:
+		return SlideShowStatus{}, ErrNotRunning
+	}
+
+	if _, ok := command.(PresentationStop); ok {
+		select {
+		case <-impr.shutdown:
+			return SlideShowStatus{State: STATE_FINISHED}, nil
+		case <-time.After(CommandTimeout):
+		}
+	} else if moves {
+		select {
+		case <-changed:
+		case <-impr.shutdown:
+		case <-time.After(CommandTimeout):
+		}
+	}
+	return impr.GetStats().Status, nil
+}
+
+// movesLocked tells whether Impress is going to report another slide after the command
+func (impr *ImpressClient) movesLocked(command ProtocolMessage) bool {
+	status := impr.stats.Status
+	if !status.IsRunning() {
+		return false
+	}
+	switch command := command.(type) {
+	case TransitionPrevious:
+		return status.CurrentSlide > 0
+	case TransitionNext:
+		return status.TotalSlides == 0 || status.CurrentSlide < status.TotalSlides-1
+	case GoToSlide:
+		return command.Index != status.CurrentSlide
+	default:
+		return false
+	}
+}
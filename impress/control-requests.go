@@ -0,0 +1,195 @@
+package impress
+
+import (
+	time "time"
+)
+
+const (
+	REQUEST_CONTROL  = "request_control"
+	APPROVE_CONTROL  = "approve_control"
+	DENY_CONTROL     = "deny_control"
+	CONTROL_REQUESTS = "control_requests"
+	CONTROL_STATUS   = "control_status"
+
+	DEFAULT_CONTROL_DURATION = 5 * time.Minute
+	MAX_CONTROL_DURATION     = time.Hour
+)
+
+type ControlState string
+
+const (
+	CONTROL_PENDING ControlState = "pending"
+	CONTROL_GRANTED ControlState = "granted"
+	CONTROL_DENIED  ControlState = "denied"
+	CONTROL_REVOKED ControlState = "revoked"
+)
+
+// A viewer asking the owner to control the presentation for a while
+type RequestControl struct{}
+
+type ApproveControl struct {
+	ControllerID string
+	Duration     time.Duration
+}
+
+type DenyControl struct {
+	ControllerID string
+}
+
+type ControlRequest struct {
+	Participant Participant
+	RequestedAt time.Time
+}
+
+// ControlRequests lists the pending requests to the owners, oldest first
+type ControlRequests struct {
+	Requests []ControlRequest
+}
+
+// ControlStatus tells a requester what happened to its request. ExpiresAt is only set once control is granted
+type ControlStatus struct {
+	State     ControlState
+	ExpiresAt time.Time
+}
+
+func (RequestControl) Command() string  { return REQUEST_CONTROL }
+func (ApproveControl) Command() string  { return APPROVE_CONTROL }
+func (DenyControl) Command() string     { return DENY_CONTROL }
+func (ControlRequests) Command() string { return CONTROL_REQUESTS }
+func (ControlStatus) Command() string   { return CONTROL_STATUS }
+
+type controlRequest struct {
+	controller  *ImpressController
+	requestedAt time.Time
+}
+
+func (impr *ImpressClient) requestControl(from *ImpressController) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	if !impr.isRegisteredLocked(from) {
+		return ErrNotJoined
+	}
+	for _, request := range impr.controlRequests {
+		if request.controller == from {
+			return NewError(ERR_CONFLICT, "Control was already requested")
+		}
+	}
+	impr.controlRequests = append(impr.controlRequests, controlRequest{controller: from, requestedAt: time.Now()})
+	Logger.InfoF("Controller %s requested control", from.participant.ID)
+
+	from.send <- ControlStatus{State: CONTROL_PENDING}
+	impr.sendControlRequestsLocked()
+	return nil
+}
+
+// approveControl makes the requester a co-presenter until the duration expires
+func (impr *ImpressClient) approveControl(controllerID string, duration time.Duration) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	request, ok := impr.removeControlRequestLocked(controllerID)
+	if !ok {
+		return NewError(ERR_NOT_FOUND, "Control request not found")
+	}
+	controller := request.controller
+	if controller.role != ROLE_VIEWER {
+		impr.sendControlRequestsLocked()
+		return NewError(ERR_CONFLICT, "Controller already has control")
+	}
+
+	controller.role = ROLE_CO_PRESENTER
+	impr.controlTimers[controller] = time.AfterFunc(duration, func() { impr.revokeControl(controller) })
+	Logger.InfoF("Controller %s was granted control for %v", controllerID, duration)
+
+	controller.send <- ControlStatus{State: CONTROL_GRANTED, ExpiresAt: time.Now().Add(duration)}
+	impr.sendControlRequestsLocked()
+	impr.sendRosterLocked()
+	return nil
+}
+
+func (impr *ImpressClient) denyControl(controllerID string) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	request, ok := impr.removeControlRequestLocked(controllerID)
+	if !ok {
+		return NewError(ERR_NOT_FOUND, "Control request not found")
+	}
+	Logger.InfoF("Controller %s was denied control", controllerID)
+
+	request.controller.send <- ControlStatus{State: CONTROL_DENIED}
+	impr.sendControlRequestsLocked()
+	return nil
+}
+
+// revokeControl turns a controller whose temporary control expired back into a viewer,
+// unless it became the owner in the meantime
+func (impr *ImpressClient) revokeControl(controller *ImpressController) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if _, ok := impr.controlTimers[controller]; !ok || impr.isTerminated {
+		return
+	}
+	delete(impr.controlTimers, controller)
+	if controller.role != ROLE_CO_PRESENTER {
+		return
+	}
+	controller.role = ROLE_VIEWER
+	Logger.InfoF("Temporary control of controller %s expired", controller.participant.ID)
+
+	controller.send <- ControlStatus{State: CONTROL_REVOKED}
+	impr.sendRosterLocked()
+}
+
+// forgetControlLocked drops the pending request and the temporary control of a controller that left
+func (impr *ImpressClient) forgetControlLocked(controller *ImpressController) {
+	if timer, ok := impr.controlTimers[controller]; ok {
+		timer.Stop()
+		delete(impr.controlTimers, controller)
+	}
+	if _, ok := impr.removeControlRequestLocked(controller.participant.ID); ok {
+		impr.sendControlRequestsLocked()
+	}
+}
+
+func (impr *ImpressClient) stopControlTimersLocked() {
+	for controller, timer := range impr.controlTimers {
+		timer.Stop()
+		delete(impr.controlTimers, controller)
+	}
+}
+
+func (impr *ImpressClient) removeControlRequestLocked(controllerID string) (controlRequest, bool) {
+	for i, request := range impr.controlRequests {
+		if request.controller.participant.ID == controllerID {
+			impr.controlRequests = append(impr.controlRequests[:i:i], impr.controlRequests[i+1:]...)
+			return request, true
+		}
+	}
+	return controlRequest{}, false
+}
+
+func (impr *ImpressClient) sendControlRequestsLocked() {
+	requests := ControlRequests{Requests: make([]ControlRequest, 0, len(impr.controlRequests))}
+	for _, request := range impr.controlRequests {
+		participant := request.controller.participant
+		participant.Role = request.controller.role
+		requests.Requests = append(requests.Requests, ControlRequest{Participant: participant, RequestedAt: request.requestedAt})
+	}
+	for _, controller := range impr.controllers {
+		if controller.IsOwner() {
+			controller.send <- requests
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package impress
+
+import (
+	zip "archive/zip"
+	xml "encoding/xml"
+	errors "errors"
+	path "path"
+	filepath "path/filepath"
+	strings "strings"
+)
+
+// Slide of the deck, as listed by the slide catalogue
+type Slide struct {
+	Index      int
+	Title      string
+	HasPreview bool
+	Notes      string
+	Visited    bool
+}
+
+type pptxPresentation struct {
+	SlideIDs []struct {
+		RelationshipID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sldIdLst>sldId"`
+}
+
+type pptxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type pptxSlide struct {
+	Shapes []struct {
+		Placeholder struct {
+			Type string `xml:"type,attr"`
+		} `xml:"nvSpPr>nvPr>ph"`
+		Paragraphs []struct {
+			Runs []string `xml:"r>t"`
+		} `xml:"txBody>p"`
+	} `xml:"cSld>spTree>sp"`
+}
+
+// GetSlides lists every slide of the deck with its title, whether its preview arrived, its notes and whether it was shown
+func (impr *ImpressClient) GetSlides() []Slide {
+	titles := impr.slideTitles()
+
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	total := impr.stats.Status.TotalSlides
+	if total == 0 {
+		total = len(titles)
+	}
+	slides := make([]Slide, 0, total)
+	for index := 0; index < total; index++ {
+		slide := Slide{Index: index, Notes: impr.notes[index], Visited: impr.visited[index]}
+		if index < len(titles) {
+			slide.Title = titles[index]
+		}
+		_, slide.HasPreview = impr.previews[index]
+		slides = append(slides, slide)
+	}
+	return slides
+}
+
+// slideTitles reads the titles from the deck the first time they are needed, since the file doesn't change
+func (impr *ImpressClient) slideTitles() []string {
+	impr.mu.Lock()
+	if impr.titles != nil || impr.presentation == nil {
+		defer impr.mu.Unlock()
+		return impr.titles
+	}
+	filePath := impr.presentation.filePath
+	impr.mu.Unlock()
+
+	titles, err := ReadSlideTitles(filePath)
+	if err != nil {
+		Logger.WarningF("Can't read the slide titles of %s: %v", filePath, err)
+		titles = make([]string, 0)
+	}
+
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+	impr.titles = titles
+	return titles
+}
+
+// ReadSlideTitles returns the title of every slide of a .pptx deck, in the order they are shown.
+// Slides without a title placeholder have an empty one. Legacy .ppt decks have no titles to read
+func ReadSlideTitles(filePath string) ([]string, error) {
+	if !strings.EqualFold(filepath.Ext(filePath), ".pptx") {
+		return make([]string, 0), nil
+	}
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var presentation pptxPresentation
+	if err := readXML(&archive.Reader, "ppt/presentation.xml", &presentation); err != nil {
+		return nil, err
+	}
+	var relationships pptxRelationships
+	if err := readXML(&archive.Reader, "ppt/_rels/presentation.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, relationship := range relationships.Relationships {
+		targets[relationship.ID] = relationship.Target
+	}
+
+	titles := make([]string, 0, len(presentation.SlideIDs))
+	for _, slideID := range presentation.SlideIDs {
+		target, ok := targets[slideID.RelationshipID]
+		if !ok {
+			return nil, errors.New("Slide relationship " + slideID.RelationshipID + " not found")
+		}
+		var slide pptxSlide
+		if err := readXML(&archive.Reader, path.Join("ppt", target), &slide); err != nil {
+			return nil, err
+		}
+		titles = append(titles, slide.title())
+	}
+	return titles, nil
+}
+
+func (slide pptxSlide) title() string {
+	for _, shape := range slide.Shapes {
+		if shape.Placeholder.Type != "title" && shape.Placeholder.Type != "ctrTitle" {
+			continue
+		}
+		lines := make([]string, 0, len(shape.Paragraphs))
+		for _, paragraph := range shape.Paragraphs {
+			if line := strings.TrimSpace(strings.Join(paragraph.Runs, "")); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, " ")
+	}
+	return ""
+}
+
+func readXML(archive *zip.Reader, name string, v interface{}) error {
+	file, err := archive.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return xml.NewDecoder(file).Decode(v)
+}
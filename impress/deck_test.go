@@ -0,0 +1,30 @@
+package impress_test
+
+import (
+	ioutil "io/ioutil"
+	filepath "path/filepath"
+	reflect "reflect"
+	testing "testing"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	impresstest "github.com/DanInci/raspi-projector-backend/impress/impresstest"
+)
+
+func TestReadSlideTitles(t *testing.T) {
+	deckPath := filepath.Join(t.TempDir(), "deck.pptx")
+	if err := ioutil.WriteFile(deckPath, impresstest.Deck("Welcome", "", "Questions?"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	titles, err := impress.ReadSlideTitles(deckPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Welcome", "", "Questions?"}; !reflect.DeepEqual(titles, expected) {
+		t.Errorf("titles are %q, expected %q", titles, expected)
+	}
+
+	if titles, err := impress.ReadSlideTitles(filepath.Join(t.TempDir(), "deck.ppt")); err != nil || len(titles) != 0 {
+		t.Errorf("legacy deck has titles %q: %v", titles, err)
+	}
+}
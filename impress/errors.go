@@ -0,0 +1,102 @@
+package impress
+
+// ErrorCode identifies an error independently of its message, which is meant for humans and may change.
+// The same codes are returned over HTTP and over the websocket
+type ErrorCode string
+
+const (
+	ERR_MALFORMED_REQUEST    ErrorCode = "MALFORMED_REQUEST"
+	ERR_UNKNOWN_COMMAND      ErrorCode = "UNKNOWN_COMMAND"
+	ERR_INVALID_ARGUMENT     ErrorCode = "INVALID_ARGUMENT"
+	ERR_INVALID_INDEX        ErrorCode = "INVALID_INDEX"
+	ERR_ROOM_NOT_FOUND       ErrorCode = "ROOM_NOT_FOUND"
+	ERR_NOT_RUNNING          ErrorCode = "NOT_RUNNING"
+	ERR_NOT_READY            ErrorCode = "NOT_READY"
+	ERR_NOT_SUPPORTED        ErrorCode = "NOT_SUPPORTED"
+	ERR_NOT_OWNER            ErrorCode = "NOT_OWNER"
+	ERR_PERMISSION_DENIED    ErrorCode = "PERMISSION_DENIED"
+	ERR_OWNER_TOKEN_REQUIRED ErrorCode = "OWNER_TOKEN_REQUIRED"
+	ERR_ROOM_FULL            ErrorCode = "ROOM_FULL"
+	ERR_BANNED               ErrorCode = "BANNED"
+	ERR_INVALID_INVITE       ErrorCode = "INVALID_INVITE"
+	ERR_UPLOAD_TOO_LARGE     ErrorCode = "UPLOAD_TOO_LARGE"
+	ERR_INVALID_UPLOAD       ErrorCode = "INVALID_UPLOAD"
+	ERR_INVALID_FILE_TYPE    ErrorCode = "INVALID_FILE_TYPE"
+	ERR_START_FAILED         ErrorCode = "START_FAILED"
+	ERR_NOT_QUEUED           ErrorCode = "NOT_QUEUED"
+	ERR_PREVIEW_NOT_FOUND    ErrorCode = "PREVIEW_NOT_FOUND"
+	ERR_NOT_FOUND            ErrorCode = "NOT_FOUND"
+	ERR_CONFLICT             ErrorCode = "CONFLICT"
+	ERR_RATE_LIMITED         ErrorCode = "RATE_LIMITED"
+	ERR_INTERNAL             ErrorCode = "INTERNAL"
+)
+
+// ErrorCodes lists the whole catalogue, in the order it is documented
+var ErrorCodes = []ErrorCode{
+	ERR_MALFORMED_REQUEST, ERR_UNKNOWN_COMMAND, ERR_INVALID_ARGUMENT, ERR_INVALID_INDEX,
+	ERR_ROOM_NOT_FOUND, ERR_NOT_RUNNING, ERR_NOT_READY, ERR_NOT_SUPPORTED,
+	ERR_NOT_OWNER, ERR_PERMISSION_DENIED, ERR_OWNER_TOKEN_REQUIRED,
+	ERR_ROOM_FULL, ERR_BANNED, ERR_INVALID_INVITE,
+	ERR_UPLOAD_TOO_LARGE, ERR_INVALID_UPLOAD, ERR_INVALID_FILE_TYPE, ERR_START_FAILED, ERR_NOT_QUEUED,
+	ERR_PREVIEW_NOT_FOUND, ERR_NOT_FOUND, ERR_CONFLICT, ERR_RATE_LIMITED, ERR_INTERNAL,
+}
+
+// Error is an error returned to a client, with a stable code and optional details a client can act upon
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Details map[string]interface{}
+}
+
+var (
+	ErrNotRunning = NewError(ERR_NOT_RUNNING, "Slideshow is not running")
+	ErrNotReady   = NewError(ERR_NOT_READY, "Slideshow is still connecting to impress")
+	ErrNotJoined  = NewError(ERR_ROOM_FULL, "Controller did not join the presentation")
+)
+
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// WithDetail returns a copy of the error with one more detail, so that shared errors are never modified
+func (err *Error) WithDetail(key string, value interface{}) *Error {
+	details := make(map[string]interface{}, len(err.Details)+1)
+	for k, v := range err.Details {
+		details[k] = v
+	}
+	details[key] = value
+	return &Error{Code: err.Code, Message: err.Message, Details: details}
+}
+
+// AsError gives a code to any error. Those that weren't meant for clients are internal
+func AsError(err error) *Error {
+	if coded, ok := err.(*Error); ok {
+		return coded
+	}
+	return NewError(ERR_INTERNAL, err.Error())
+}
+
+// EncodeError is the body of an error on both transports. The message is repeated under "error",
+// which is what clients read before error codes existed
+func EncodeError(err error) map[string]interface{} {
+	coded := AsError(err)
+	details := coded.Details
+	if details == nil {
+		details = make(map[string]interface{})
+	}
+	return map[string]interface{}{
+		"code":    coded.Code,
+		"message": coded.Message,
+		"details": details,
+		"error":   coded.Message,
+	}
+}
+
+// fieldError is an invalid argument of a request, with the field at fault
+func fieldError(code ErrorCode, field string, message string) *Error {
+	return NewError(code, message).WithDetail("field", field)
+}
@@ -1,10 +1,12 @@
 package impress
 
 import (
+	hex "encoding/hex"
 	json "encoding/json"
 	errors "errors"
 	strconv "strconv"
 	strings "strings"
+	sync "sync"
 	time "time"
 
 	websocket "github.com/gorilla/websocket"
@@ -13,6 +15,16 @@ import (
 const (
 	PAIR_WITH_SERVER = "LO_SERVER_CLIENT_PAIR"
 
+	SESSION_STATUS = "session_status"
+
+	CONTROLLER_INFO    = "controller_info"
+	TRANSFER_OWNERSHIP = "transfer_ownership"
+	CREATE_INVITE      = "create_invite"
+	INVITE_CREATED     = "invite_created"
+	ROSTER             = "roster"
+	OWNERSHIP_GRANTED  = "ownership_granted"
+	OWNER_CHANGED      = "owner_changed"
+
 	TRANSITION_NEXT           = "transition_next"
 	TRANSITION_PREVIOUS       = "transition_previous"
 	GO_TO_SLIDE               = "goto_slide"
@@ -27,26 +39,156 @@ const (
 )
 
 type ImpressController struct {
-	conn    *websocket.Conn
-	isOwner bool
-	send    chan []string
+	participant Participant
+	conn        *websocket.Conn
+	role        Role
+	resumeToken string
+	send        chan Message
+	// joined tells the read pump whether the client let the controller in, or turned it away because the room is full
+	joined  chan bool
+	writeMu sync.Mutex
+}
+
+// Participant identifies the person behind a controller. The ID is public, it stays the same when the participant
+// reconnects with its resume token
+type Participant struct {
+	ID          string
+	Name        string
+	Role        Role
+	RemoteAddr  string
+	ConnectedAt time.Time
+}
+
+// Slide status pushed to the controllers, together with the preview of the current slide
+type SlideStatus struct {
+	Status  ProtocolMessage
+	Preview string
+}
+
+func (s SlideStatus) Command() string {
+	return s.Status.Command()
+}
+
+type SessionStatus struct {
+	State SessionState
+}
+
+func (SessionStatus) Command() string {
+	return SESSION_STATUS
+}
+
+// ControllerInfo is sent to every controller when it joins. Its ID is what the owner hands the presentation over to,
+// and its resume token, only ever sent to the controller itself, is what keeps that ID when reconnecting
+type ControllerInfo struct {
+	ID          string
+	Name        string
+	Role        Role
+	ResumeToken string
+}
+
+func (ControllerInfo) Command() string {
+	return CONTROLLER_INFO
+}
+
+type TransferOwnership struct {
+	ControllerID string
+}
+
+func (TransferOwnership) Command() string {
+	return TRANSFER_OWNERSHIP
+}
+
+// OwnershipGranted carries the fresh owner token to the controller that received the presentation
+type OwnershipGranted struct {
+	OwnerUUID string
+}
+
+func (OwnershipGranted) Command() string {
+	return OWNERSHIP_GRANTED
+}
+
+type OwnerChanged struct {
+	ControllerID string
+}
+
+func (OwnerChanged) Command() string {
+	return OWNER_CHANGED
+}
+
+type CreateInvite struct {
+	Role Role
+}
+
+func (CreateInvite) Command() string {
+	return CREATE_INVITE
+}
+
+// InviteCreated carries a token that grants its role to whoever connects with it
+type InviteCreated struct {
+	Token string
+	Role  Role
+}
+
+func (InviteCreated) Command() string {
+	return INVITE_CREATED
+}
+
+// Roster lists the connected participants. Only owners receive it, since it exposes their addresses
+type Roster struct {
+	Participants []Participant
+}
+
+func (Roster) Command() string {
+	return ROSTER
 }
 
 const (
 	writeWait       = 10 * time.Second
 	pongWait        = 60 * time.Second
 	pingPeriod      = (pongWait * 9) / 10
-	readBufferSize  = 1024
 	writeBufferSize = 1024
+	// Messages waiting for the write pump. Controllers falling further behind are dropped
+	sendBufferSize = 64
 )
 
-func NewController(socket *websocket.Conn, isOwner bool) *ImpressController {
-	controller := &ImpressController{conn: socket, isOwner: isOwner, send: make(chan []string)}
+// Largest request a controller can send is a poll at its limits, with every rune escaped as a JSON surrogate pair
+const maxMessageSize = (MAX_QUESTION_LENGTH+MAX_POLL_OPTIONS*MAX_POLL_OPTION_LENGTH)*len(`\ud83c\udf89`) + 1024
+
+// NewController creates a controller for the participant. Participants resuming with a token keep their ID,
+// the others get a new ID and a new token
+func NewController(socket *websocket.Conn, role Role, participant Participant, resumeToken string) *ImpressController {
+	if !IsValidParticipantID(participant.ID) || resumeToken == "" {
+		participant.ID = newToken(8)
+		resumeToken = newToken(16)
+	}
+	controller := &ImpressController{participant: participant, conn: socket, role: role, resumeToken: resumeToken, send: make(chan Message, sendBufferSize), joined: make(chan bool, 1)}
 	return controller
 }
 
+func IsValidParticipantID(id string) bool {
+	decoded, err := hex.DecodeString(id)
+	return err == nil && len(decoded) == 8
+}
+
+func (c *ImpressController) ID() string {
+	return c.participant.ID
+}
+
+// IsOwner must only be called while holding the lock of the client the controller is registered with,
+// since ownership can be transferred at any time
 func (c *ImpressController) IsOwner() bool {
-	return c.isOwner
+	return c.role == ROLE_OWNER
+}
+
+// offer queues a message for the write pump without waiting for it. A controller whose buffer is full has stopped
+// reading, its websocket is closed so that it unregisters instead of holding up everybody else
+func (c *ImpressController) offer(message Message) {
+	select {
+	case c.send <- message:
+	default:
+		Logger.WarningF("Dropped controller %s that fell behind", c.participant.ID)
+		c.conn.Close()
+	}
 }
 
 func (c *ImpressController) StartPumping(client *ImpressClient) {
@@ -56,13 +198,30 @@ func (c *ImpressController) StartPumping(client *ImpressClient) {
 
 func (controller *ImpressController) readPump(client *ImpressClient) {
 	defer func() {
-		client.unregister <- controller
+		select {
+		case client.unregister <- controller:
+		case <-client.Done():
+		}
 		controller.conn.Close()
 	}()
-	client.register <- controller
-	controller.conn.SetReadLimit(readBufferSize)
+	select {
+	case client.register <- controller:
+	case <-client.Done():
+		// Nobody registers controllers anymore, closing its channel lets the write pump close the websocket
+		close(controller.send)
+		return
+	}
+	controller.conn.SetReadLimit(int64(maxMessageSize))
 	controller.conn.SetReadDeadline(time.Now().Add(pongWait))
 	controller.conn.SetPongHandler(func(string) error { controller.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	if !<-controller.joined {
+		// Nothing is handled for a controller that never joined, its websocket is only read until the write pump closes it
+		for {
+			if _, _, err := controller.conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
 	for {
 		_, message, err := controller.conn.ReadMessage()
 		if err != nil {
@@ -70,64 +229,323 @@ func (controller *ImpressController) readPump(client *ImpressClient) {
 		}
 		request, err2 := decodeRequest(message)
 		if err2 != nil {
-			controller.writeError(err2.Error())
+			controller.writeError(err2)
 			continue
 		}
 
-		if !controller.IsOwner() {
-			controller.writeError("Only the owner can control the presentation")
+		if role := client.controllerRole(controller); !role.Allows(request) {
+			controller.writeError(role.permissionError())
 			continue
 		}
 
-		client.requests <- request
+		switch request := request.(type) {
+		case TransferOwnership:
+			if err := client.transferOwnership(controller, request.ControllerID); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case CreateInvite:
+			if err := client.createInvite(controller, request.Role); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case RequestControl:
+			if err := client.requestControl(controller); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case ApproveControl:
+			if err := client.approveControl(request.ControllerID, request.Duration); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case DenyControl:
+			if err := client.denyControl(request.ControllerID); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case AskQuestion:
+			if err := client.askQuestion(controller, request.Text); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case UpvoteQuestion:
+			if err := client.upvoteQuestion(controller, request.QuestionID); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case AnswerQuestion:
+			if err := client.closeQuestion(request.QuestionID, QUESTION_ANSWERED); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case DismissQuestion:
+			if err := client.closeQuestion(request.QuestionID, QUESTION_DISMISSED); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case OpenPoll:
+			if err := client.openPoll(request.Question, request.Options); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case ClosePoll:
+			if err := client.closePoll(); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case Vote:
+			if err := client.vote(controller, request.PollID, request.Option); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case React:
+			if err := client.react(request.Emoji); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case ListControllers:
+			client.listControllers(controller)
+			continue
+		case Kick:
+			if err := client.kick(request.ControllerID, request.Reason); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		case Ban:
+			if err := client.ban(request.ControllerID, request.Reason, request.ByAddress); err != nil {
+				controller.writeError(err)
+			}
+			continue
+		}
+
+		if session := client.GetStats().Session; session == SESSION_CONNECTING || session == SESSION_PAIRING {
+			controller.writeError(ErrNotReady)
+			continue
+		}
+
+		command := request.(ProtocolMessage)
+		if isPointerRequest(command) && !client.Supports(CAPABILITY_POINTER) {
+			controller.writeError(NewError(ERR_NOT_SUPPORTED, "Pointer is not supported by this LibreOffice version"))
+			continue
+		}
+
+		select {
+		case client.requests <- impressRequest{command: command, from: controller}:
+		case <-client.Done():
+			return
+		}
 	}
 }
 
-func decodeRequest(body []byte) ([]string, error) {
-	var decoded map[string]string
-	if err := json.Unmarshal(body, &decoded); err != nil {
-		return nil, errors.New("Malformed JSON syntax")
+// decodeRequest returns either a ProtocolMessage for Impress or a request handled by the client itself
+func decodeRequest(body []byte) (Message, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, NewError(ERR_MALFORMED_REQUEST, "Malformed JSON syntax")
+	}
+	// Values are read as strings, numbers included, except for the few lists like the options of a poll
+	decoded := make(map[string]string)
+	for key, value := range raw {
+		switch value := value.(type) {
+		case string:
+			decoded[key] = value
+		case float64:
+			decoded[key] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
 	}
 	value, ok := decoded["command"]
 	if !ok {
-		return nil, errors.New("command key not found")
+		return nil, fieldError(ERR_MALFORMED_REQUEST, "command", "command key not found")
 	}
 	switch value {
-	case TRANSITION_NEXT, TRANSITION_PREVIOUS, PRESENTATION_BLANK_SCREEN, PRESENTATION_RESUME, PRESENTATION_START, PRESENTATION_STOP:
+	case TRANSITION_NEXT:
+		return TransitionNext{}, nil
+	case TRANSITION_PREVIOUS:
+		return TransitionPrevious{}, nil
+	case PRESENTATION_BLANK_SCREEN:
+		return PresentationBlankScreen{}, nil
+	case PRESENTATION_RESUME:
+		return PresentationResume{}, nil
+	case PRESENTATION_START:
+		return PresentationStart{}, nil
+	case PRESENTATION_STOP:
+		return PresentationStop{}, nil
 	case GO_TO_SLIDE:
-		value, ok := decoded["index"]
+		index, ok := decoded["index"]
 		if !ok {
-			return nil, errors.New("index key required")
+			return nil, fieldError(ERR_INVALID_INDEX, "index", "index key required")
 		}
-		conv, err := strconv.Atoi(value)
+		conv, err := strconv.Atoi(index)
 		if err != nil || conv < 0 {
-			return nil, errors.New("index value not a number or less than 0")
+			return nil, fieldError(ERR_INVALID_INDEX, "index", "index value not a number or less than 0")
+		}
+		return GoToSlide{Index: conv}, nil
+	case POINTER_STARTED, POINTER_COORDINATION:
+		x, err := decodeCoordinate(decoded, "x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeCoordinate(decoded, "y")
+		if err != nil {
+			return nil, err
+		}
+		if value == POINTER_STARTED {
+			return PointerStarted{X: x, Y: y}, nil
+		}
+		return PointerCoordination{X: x, Y: y}, nil
+	case POINTER_DISMISSED:
+		return PointerDismissed{}, nil
+	case TRANSFER_OWNERSHIP:
+		controllerID, ok := decoded["controllerID"]
+		if !ok || controllerID == "" {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "controllerID", "controllerID key required")
+		}
+		return TransferOwnership{ControllerID: controllerID}, nil
+	case CREATE_INVITE:
+		role, ok := ParseRole(decoded["role"])
+		if !ok {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "role", "role value must be co_presenter or viewer")
+		}
+		return CreateInvite{Role: role}, nil
+	case REQUEST_CONTROL:
+		return RequestControl{}, nil
+	case APPROVE_CONTROL, DENY_CONTROL:
+		controllerID, ok := decoded["controllerID"]
+		if !ok || controllerID == "" {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "controllerID", "controllerID key required")
+		}
+		if value == DENY_CONTROL {
+			return DenyControl{ControllerID: controllerID}, nil
+		}
+		duration := DEFAULT_CONTROL_DURATION
+		if seconds, ok := decoded["duration"]; ok {
+			conv, err := strconv.Atoi(seconds)
+			if err != nil || conv <= 0 || time.Duration(conv)*time.Second > MAX_CONTROL_DURATION {
+				return nil, fieldError(ERR_INVALID_ARGUMENT, "duration", "duration value must be a number of seconds, up to an hour")
+			}
+			duration = time.Duration(conv) * time.Second
+		}
+		return ApproveControl{ControllerID: controllerID, Duration: duration}, nil
+	case ASK_QUESTION:
+		text, ok := decoded["text"]
+		if !ok {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "text", "text key required")
+		}
+		return AskQuestion{Text: text}, nil
+	case UPVOTE_QUESTION, ANSWER_QUESTION, DISMISS_QUESTION:
+		questionID, ok := decoded["questionID"]
+		if !ok || questionID == "" {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "questionID", "questionID key required")
+		}
+		switch value {
+		case UPVOTE_QUESTION:
+			return UpvoteQuestion{QuestionID: questionID}, nil
+		case ANSWER_QUESTION:
+			return AnswerQuestion{QuestionID: questionID}, nil
+		default:
+			return DismissQuestion{QuestionID: questionID}, nil
+		}
+	case OPEN_POLL:
+		question, ok := decoded["question"]
+		if !ok {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "question", "question key required")
+		}
+		options, ok := decodeList(raw, "options")
+		if !ok {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "options", "options key must be a list of strings")
+		}
+		return OpenPoll{Question: question, Options: options}, nil
+	case CLOSE_POLL:
+		return ClosePoll{}, nil
+	case VOTE:
+		pollID, ok := decoded["pollID"]
+		if !ok || pollID == "" {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "pollID", "pollID key required")
+		}
+		option, err := strconv.Atoi(decoded["option"])
+		if err != nil {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "option", "option value not a number")
+		}
+		return Vote{PollID: pollID, Option: option}, nil
+	case REACT:
+		emoji, ok := decoded["emoji"]
+		if !ok {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "emoji", "emoji key required")
+		}
+		return React{Emoji: emoji}, nil
+	case LIST_CONTROLLERS:
+		return ListControllers{}, nil
+	case KICK, BAN:
+		controllerID, ok := decoded["controllerID"]
+		if !ok || controllerID == "" {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "controllerID", "controllerID key required")
+		}
+		if value == KICK {
+			return Kick{ControllerID: controllerID, Reason: decoded["reason"]}, nil
+		}
+		by := decoded["by"]
+		if by != "" && by != BAN_BY_PARTICIPANT && by != BAN_BY_ADDRESS {
+			return nil, fieldError(ERR_INVALID_ARGUMENT, "by", "by value must be participant or address")
 		}
+		return Ban{ControllerID: controllerID, Reason: decoded["reason"], ByAddress: by == BAN_BY_ADDRESS}, nil
 	default:
-		return nil, errors.New("command not recognized")
+		return nil, NewError(ERR_UNKNOWN_COMMAND, "command not recognized")
 	}
+}
 
-	request := make([]string, 0, len(decoded))
-	for _, value := range decoded {
-		request = append(request, value)
+func decodeList(raw map[string]interface{}, key string) ([]string, bool) {
+	values, ok := raw[key].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		list = append(list, str)
 	}
-	return request, nil
+	return list, true
 }
 
-func (controller *ImpressController) writeError(message string) {
-	w, err := controller.conn.NextWriter(websocket.TextMessage)
+// decodeCoordinate validates a pointer coordinate, normalized to the [0, 1] range of the slide
+func decodeCoordinate(decoded map[string]string, key string) (float64, error) {
+	value, ok := decoded[key]
+	if !ok {
+		return 0, fieldError(ERR_INVALID_ARGUMENT, key, key+" key required")
+	}
+	conv, err := parseCoordinate(value)
 	if err != nil {
-		return
+		return 0, fieldError(ERR_INVALID_ARGUMENT, key, key+" value not a number between 0 and 1")
 	}
+	return conv, nil
+}
 
-	toEncode := make(map[string]interface{})
-	toEncode["error"] = message
-	encoded, _ := json.Marshal(toEncode)
-	w.Write(encoded)
+func (controller *ImpressController) writeError(err error) {
+	encoded, _ := json.Marshal(EncodeError(err))
+	controller.write(websocket.TextMessage, encoded)
+}
 
-	if err := w.Close(); err != nil {
-		return
+// RejectConnection tells a controller why it can't join, over a websocket upgraded only for that, and closes it
+func RejectConnection(conn *websocket.Conn, err *Error, closeCode int) {
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if encoded, encodeErr := json.Marshal(EncodeError(err)); encodeErr == nil {
+		conn.WriteMessage(websocket.TextMessage, encoded)
 	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, string(err.Code)))
+	conn.Close()
+}
+
+// write sends a single message. Errors are written by the read pump, so writes have to be serialized,
+// websocket connections support only one concurrent writer
+func (controller *ImpressController) write(messageType int, data []byte) error {
+	controller.writeMu.Lock()
+	defer controller.writeMu.Unlock()
+
+	controller.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return controller.conn.WriteMessage(messageType, data)
 }
 
 func (controller *ImpressController) writePump() {
@@ -135,74 +553,159 @@ func (controller *ImpressController) writePump() {
 	defer func() {
 		ticker.Stop()
 		controller.conn.Close()
+		// Keep draining so the client never blocks on a controller that stopped writing
+		go func() {
+			for range controller.send {
+			}
+		}()
 	}()
 	for {
 		select {
 		case message, ok := <-controller.send:
-			controller.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				controller.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				controller.write(websocket.CloseMessage, []byte{})
 				return
 			}
-
-			w, err1 := controller.conn.NextWriter(websocket.TextMessage)
-			if err1 != nil {
+			if message, ok := message.(disconnect); ok {
+				if message.Error != nil {
+					controller.writeError(message.Error)
+				}
+				controller.write(websocket.CloseMessage, websocket.FormatCloseMessage(message.Code, message.Reason))
 				return
 			}
 
-			response, err2 := encodeResponse(message)
-			if err2 != nil {
-				Logger.Error(err2.Error())
-			} else {
-				w.Write(response)
+			response, err := encodeResponse(message)
+			if err != nil {
+				Logger.Error(err.Error())
+				continue
 			}
-
-			if err := w.Close(); err != nil {
+			if err := controller.write(websocket.TextMessage, response); err != nil {
 				return
 			}
 		case <-ticker.C:
-			controller.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := controller.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := controller.write(websocket.PingMessage, nil); err != nil {
 				return
 			}
 		}
 	}
 }
 
-func encodeResponse(message []string) ([]byte, error) {
+func encodeResponse(message Message) ([]byte, error) {
 	toEncode := make(map[string]interface{})
+	toEncode["command"] = message.Command()
 
-	if len(message) > 0 {
-		toEncode["command"] = message[0]
-		switch message[0] {
-		case SLIDE_SHOW_FINISHED:
-		case SLIDE_SHOW_STARTED:
-			totalSlides, _ := strconv.Atoi(message[1])
-			currentSlide, _ := strconv.Atoi(message[2])
-			toEncode["totalSlides"] = totalSlides
-			toEncode["currentSlide"] = currentSlide
-			if message[3] == "" {
-				toEncode["preview"] = ""
-
-			} else {
-				toEncode["preview"] = strings.Join([]string{"data:image/png;base64,", message[3]}, "")
-			}
-		case SLIDE_UPDATED:
-			currentSlide, _ := strconv.Atoi(message[1])
-			toEncode["currentSlide"] = currentSlide
-			if message[2] == "" {
-				toEncode["preview"] = ""
-
-			} else {
-				toEncode["preview"] = strings.Join([]string{"data:image/png;base64,", message[2]}, "")
-			}
+	switch message := message.(type) {
+	case SlideStatus:
+		switch status := message.Status.(type) {
+		case SlideShowFinished:
+		case SlideShowStarted:
+			toEncode["totalSlides"] = status.Total
+			toEncode["currentSlide"] = status.Current
+			toEncode["preview"] = encodePreview(message.Preview)
+		case SlideUpdated:
+			toEncode["currentSlide"] = status.Current
+			toEncode["preview"] = encodePreview(message.Preview)
 		default:
 			return nil, errors.New("Failed to encode command")
 		}
-	} else {
-		return nil, errors.New("Empty message command")
+	case PointerStarted:
+		toEncode["x"] = message.X
+		toEncode["y"] = message.Y
+	case PointerCoordination:
+		toEncode["x"] = message.X
+		toEncode["y"] = message.Y
+	case PointerDismissed:
+	case SessionStatus:
+		toEncode["state"] = message.State
+	case SessionEnded:
+		toEncode["reason"] = message.Reason
+	case Notes:
+		toEncode["slide"] = message.Slide
+		toEncode["notes"] = message.Notes
+	case ControllerInfo:
+		toEncode["controllerID"] = message.ID
+		toEncode["name"] = message.Name
+		toEncode["role"] = message.Role
+		toEncode["resumeToken"] = message.ResumeToken
+	case OwnershipGranted:
+		toEncode["ownerUUID"] = message.OwnerUUID
+	case OwnerChanged:
+		toEncode["controllerID"] = message.ControllerID
+	case InviteCreated:
+		toEncode["token"] = message.Token
+		toEncode["role"] = message.Role
+	case Roster:
+		participants := make([]map[string]interface{}, 0, len(message.Participants))
+		for _, participant := range message.Participants {
+			participants = append(participants, encodeParticipant(participant))
+		}
+		toEncode["participants"] = participants
+	case ControlRequests:
+		requests := make([]map[string]interface{}, 0, len(message.Requests))
+		for _, request := range message.Requests {
+			encoded := encodeParticipant(request.Participant)
+			encoded["requestedAt"] = request.RequestedAt
+			requests = append(requests, encoded)
+		}
+		toEncode["requests"] = requests
+	case Questions:
+		questions := make([]map[string]interface{}, 0, len(message.Questions))
+		for _, question := range message.Questions {
+			questions = append(questions, EncodeQuestion(question))
+		}
+		toEncode["questions"] = questions
+	case Poll:
+		total := 0
+		for _, tally := range message.Tallies {
+			total += tally
+		}
+		toEncode["pollID"] = message.ID
+		toEncode["question"] = message.Question
+		toEncode["options"] = message.Options
+		toEncode["tallies"] = message.Tallies
+		toEncode["totalVotes"] = total
+		toEncode["isOpen"] = message.IsOpen
+	case Reactions:
+		toEncode["counts"] = message.Counts
+	case ControlStatus:
+		toEncode["state"] = message.State
+		if message.State == CONTROL_GRANTED {
+			toEncode["expiresAt"] = message.ExpiresAt
+		}
+	default:
+		return nil, errors.New("Failed to encode command")
 	}
 
 	encoded, _ := json.Marshal(toEncode)
 	return encoded, nil
 }
+
+func encodeParticipant(participant Participant) map[string]interface{} {
+	return map[string]interface{}{
+		"controllerID": participant.ID,
+		"name":         participant.Name,
+		"role":         participant.Role,
+		"remoteAddr":   participant.RemoteAddr,
+		"connectedAt":  participant.ConnectedAt,
+	}
+}
+
+// EncodeQuestion leaves out who asked and who upvoted, only the name of the author and the number of upvotes are public
+func EncodeQuestion(question Question) map[string]interface{} {
+	return map[string]interface{}{
+		"questionID": question.ID,
+		"text":       question.Text,
+		"slide":      question.Slide,
+		"authorName": question.AuthorName,
+		"askedAt":    question.AskedAt,
+		"upvotes":    len(question.Upvoters),
+		"state":      question.State,
+	}
+}
+
+func encodePreview(preview string) string {
+	if preview == "" {
+		return ""
+	}
+	return strings.Join([]string{"data:image/png;base64,", preview}, "")
+}
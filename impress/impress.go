@@ -1,7 +1,8 @@
 package impress
 
 import (
-	bufio "bufio"
+	rand "crypto/rand"
+	hex "encoding/hex"
 	errors "errors"
 	net "net"
 	url "net/url"
@@ -9,6 +10,7 @@ import (
 	exec "os/exec"
 	filepath "path/filepath"
 	runtime "runtime"
+	sort "sort"
 	strconv "strconv"
 	strings "strings"
 	sync "sync"
@@ -16,15 +18,24 @@ import (
 	time "time"
 
 	log "github.com/apsdehal/go-logger"
+	websocket "github.com/gorilla/websocket"
 )
 
 var Logger *log.Logger
 
+// Replaceable so the client can be driven without a LibreOffice installation
+var OfficeLauncher = launchOffice
+var PairingDelay = 5 * time.Second
+var ReconnectDelay = time.Second
+
+const MAX_RECONNECT_DELAY = 30 * time.Second
+
 var currentConfig *configuration = &DefaultConfig
 
 const (
-	PAIRED     = "LO_SERVER_SERVER_PAIRED"
-	VALIDATING = "LO_SERVER_VALIDATING_PIN"
+	PAIRED      = "LO_SERVER_SERVER_PAIRED"
+	VALIDATING  = "LO_SERVER_VALIDATING_PIN"
+	SERVER_INFO = "LO_SERVER_INFO"
 
 	SLIDE_SHOW_INFO     = "slideshow_info"
 	SLIDE_SHOW_STARTED  = "slideshow_started"
@@ -40,96 +51,190 @@ var DefaultConfig = configuration{
 	remotePIN:       "12345",
 	maxControllers:  10,
 	ownerTimeout:    60,
+	sharePointer:    false,
+	maxReconnects:   5,
 }
 
+type SessionState string
+
+const (
+	SESSION_CONNECTING   SessionState = "connecting"
+	SESSION_PAIRING      SessionState = "pairing"
+	SESSION_RUNNING      SessionState = "running"
+	SESSION_RECONNECTING SessionState = "reconnecting"
+	SESSION_TERMINATED   SessionState = "terminated"
+)
+
 type ImpressStats struct {
 	Name           string
-	Status         []string
+	Session        SessionState
+	Status         SlideShowStatus
+	Server         ServerInfo
 	Controllers    int
 	MaxControllers int
 	IsOwnerPresent bool
 	OwnerTimeout   int
+	Roles          map[Role]int
+	Subscribers    int
 }
 
 type ImpressClient struct {
 	conn         net.Conn
+	encoder      *Encoder
+	decoder      *Decoder
 	configs      configuration
 	presentation *presentation
 	stats        ImpressStats
-	previews     map[string]string
+	previews     map[int]string
+	notes        map[int]string
+	visited      map[int]bool
+	titles       []string
+	invites      map[string]Role
 	controllers  []*ImpressController
-	isTerminated bool
-	shutdown     chan bool
-	requests     chan []string
-	messages     chan []string
-	register     chan *ImpressController
-	unregister   chan *ImpressController
-	ticker       *time.Ticker
-	mu           sync.Mutex
+	subscribers  map[chan Message]bool
+	// Pending requests for control, and the timers revoking the temporary control granted to a request
+	controlRequests []controlRequest
+	controlTimers   map[*ImpressController]*time.Timer
+	questions       []*Question
+	lastQuestionAt  map[string]time.Time
+	poll            *Poll
+	reactions       map[string]int
+	// Participant ID of every resume token handed out, kept for the whole session
+	resumeTokens map[string]string
+	// Kept for the whole session, so that banned participants can't come back
+	bannedParticipants map[string]bool
+	bannedAddresses    map[string]bool
+	// Closed and replaced whenever the slideshow status changes, to wake up whoever waits for it
+	statusChanged  chan struct{}
+	isTerminated   bool
+	shutdown       chan bool
+	requests       chan impressRequest
+	messages       chan ProtocolMessage
+	register       chan *ImpressController
+	unregister     chan *ImpressController
+	ticker         *time.Ticker
+	restored       bool
+	listener       func()
+	endedOwnerUUID string
+	summary        *SessionSummary
+	mu             sync.Mutex
 }
 
 type configuration struct {
-	libreOfficePath string
-	remoteURL       string
-	remoteName      string
-	remotePIN       string
-	maxControllers  int
-	ownerTimeout    int
+	libreOfficePath  string
+	profileDirectory string
+	remoteURL        string
+	remoteName       string
+	remotePIN        string
+	maxControllers   int
+	ownerTimeout     int
+	sharePointer     bool
+	maxReconnects    int
 }
 
 type presentation struct {
 	uuid     string
 	filePath string
-	command  *exec.Cmd
+	process  *os.Process
+	// Tells the process apart from a later one reusing its PID, empty where it can't be read
+	processIdentity string
+	startedAt       time.Time
 }
 
-func Configure(librePath string, remoteURL string, remoteName string, remotePIN string, maxControllers int, ownerTimeout int) {
+func Configure(librePath string, remoteName string, remotePIN string, maxControllers int, ownerTimeout int, sharePointer bool, maxReconnects int) {
 	currentConfig = &configuration{
 		libreOfficePath: librePath,
-		remoteURL:       remoteURL,
 		remoteName:      remoteName,
 		remotePIN:       remotePIN,
 		maxControllers:  maxControllers,
 		ownerTimeout:    ownerTimeout,
+		sharePointer:    sharePointer,
+		maxReconnects:   maxReconnects,
 	}
 }
 
-func NewClient() *ImpressClient {
+// NewClient creates a client for the LibreOffice remote listening on remoteURL. LibreOffice is started with
+// its default user profile, unless a profile directory is given, which allows several instances to run side by side
+func NewClient(remoteURL string, profileDirectory string) *ImpressClient {
+	configs := *currentConfig
+	configs.remoteURL = remoteURL
+	configs.profileDirectory = profileDirectory
 	client := &ImpressClient{
-		conn:         nil,
-		configs:      *currentConfig,
-		presentation: nil,
-		stats:        ImpressStats{Name: "", Status: make([]string, 0), Controllers: 0, MaxControllers: currentConfig.maxControllers, IsOwnerPresent: false, OwnerTimeout: currentConfig.ownerTimeout},
-		previews:     make(map[string]string),
-		controllers:  make([]*ImpressController, 0),
-		isTerminated: false,
-		shutdown:     make(chan bool),
-		requests:     make(chan []string),
-		messages:     make(chan []string),
-		register:     make(chan *ImpressController),
-		unregister:   make(chan *ImpressController),
-		ticker:       nil,
-		mu:           sync.Mutex{},
+		conn:               nil,
+		encoder:            nil,
+		decoder:            nil,
+		configs:            configs,
+		presentation:       nil,
+		stats:              ImpressStats{Name: "", Session: SESSION_CONNECTING, Status: SlideShowStatus{State: STATE_IDLE}, Server: NewServerInfo(""), Controllers: 0, MaxControllers: currentConfig.maxControllers, IsOwnerPresent: false, OwnerTimeout: currentConfig.ownerTimeout},
+		previews:           make(map[int]string),
+		notes:              make(map[int]string),
+		visited:            make(map[int]bool),
+		invites:            make(map[string]Role),
+		controllers:        make([]*ImpressController, 0),
+		subscribers:        make(map[chan Message]bool),
+		controlRequests:    make([]controlRequest, 0),
+		controlTimers:      make(map[*ImpressController]*time.Timer),
+		questions:          make([]*Question, 0),
+		lastQuestionAt:     make(map[string]time.Time),
+		reactions:          make(map[string]int),
+		resumeTokens:       make(map[string]string),
+		bannedParticipants: make(map[string]bool),
+		bannedAddresses:    make(map[string]bool),
+		statusChanged:      make(chan struct{}),
+		isTerminated:       false,
+		shutdown:           make(chan bool),
+		requests:           make(chan impressRequest),
+		messages:           make(chan ProtocolMessage),
+		register:           make(chan *ImpressController),
+		unregister:         make(chan *ImpressController),
+		ticker:             nil,
+		mu:                 sync.Mutex{},
 	}
 	go client.handleRegistrations()
 	return client
 }
 
+// newToken returns a random hex string of the given number of bytes
+func newToken(size int) string {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(bytes)
+}
+
 func (impr *ImpressClient) StartPresentation(uuid string, path string) error {
-	cmd := exec.Command(impr.configs.libreOfficePath, "--invisible", "--norestore", "--show", path)
+	cmd := OfficeLauncher(impr.configs.libreOfficePath, impr.configs.profileDirectory, path)
 
 	if err := cmd.Start(); err != nil {
 		return err
 	} else {
+		impr.mu.Lock()
+		identity, _ := ProcessIdentity(cmd.Process.Pid)
 		impr.presentation = &presentation{
-			uuid:     uuid,
-			filePath: path,
-			command:  cmd,
+			uuid:            uuid,
+			filePath:        path,
+			process:         cmd.Process,
+			processIdentity: identity,
+			startedAt:       time.Now(),
 		}
+		impr.mu.Unlock()
 		return nil
 	}
 }
 
+func launchOffice(librePath string, profileDirectory string, path string) *exec.Cmd {
+	args := []string{"--invisible", "--norestore", "--show", path}
+	if profileDirectory != "" {
+		if absolute, err := filepath.Abs(profileDirectory); err == nil {
+			profileDirectory = absolute
+		}
+		profileURL := "file:///" + strings.TrimPrefix(filepath.ToSlash(profileDirectory), "/")
+		args = append([]string{"-env:UserInstallation=" + profileURL}, args...)
+	}
+	return exec.Command(librePath, args...)
+}
+
 func (impr *ImpressClient) OpenConnection() error {
 	u, err := url.Parse(impr.configs.remoteURL)
 	if err != nil {
@@ -152,34 +257,162 @@ func (impr *ImpressClient) OpenConnection() error {
 		return err
 	}
 
-	time.Sleep(5 * time.Second)
-	err1 := sendRequest([]string{PAIR_WITH_SERVER, impr.configs.remoteName, impr.configs.remotePIN}, rawConn)
+	time.Sleep(PairingDelay)
+	return impr.pair(rawConn)
+}
+
+func (impr *ImpressClient) pair(rawConn net.Conn) error {
+	encoder := NewEncoder(rawConn)
+	decoder := NewDecoder(rawConn, DEFAULT_MAX_MESSAGE_SIZE)
+	err1 := encoder.EncodeMessage(PairRequest{Name: impr.configs.remoteName, PIN: impr.configs.remotePIN})
 	if err1 != nil {
 		rawConn.Close()
 		return err1
 	}
 
-	messages, err2 := readMessage(rawConn)
+	message, err2 := impr.readHandshake(decoder)
 	if err2 != nil {
 		rawConn.Close()
 		return err2
 	}
-	if messages[0] == VALIDATING {
-		Logger.NoticeF("Waiting for remote %s to be authorised...", impr.configs.remoteName)
-		if _, err := readMessage(rawConn); err != nil {
+	switch message.(type) {
+	case ValidatingPIN:
+		Logger.NoticeF("Waiting for remote %s to be authorised with PIN %s...", impr.configs.remoteName, impr.configs.remotePIN)
+		impr.setSession(SESSION_PAIRING)
+		if _, err := impr.readHandshake(decoder); err != nil {
 			rawConn.Close()
 			return errors.New("Failed to authorise remote")
 		}
 		Logger.Notice("Remote successfully authorised")
-	} else if messages[0] != PAIRED {
+	case Paired:
+	default:
 		rawConn.Close()
 		return errors.New("Failed connection handshake")
 	}
 
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		rawConn.Close()
+		return errors.New("Impress client terminated while pairing")
+	}
 	impr.conn = rawConn
+	impr.encoder = encoder
+	impr.decoder = decoder
+	return nil
+}
+
+// readHandshake returns the next pairing message, recording the server info that may come before it
+func (impr *ImpressClient) readHandshake(decoder *Decoder) (ProtocolMessage, error) {
+	for {
+		message, err := decoder.DecodeMessage()
+		if err != nil {
+			return nil, err
+		}
+		if version, ok := message.(ServerVersion); ok {
+			impr.setServerInfo(version)
+			continue
+		}
+		return message, nil
+	}
+}
+
+func (impr *ImpressClient) setServerInfo(version ServerVersion) {
+	info := NewServerInfo(version.Version)
+	Logger.InfoF("Connected to LibreOffice %s with capabilities %v", info.Version, info.Capabilities)
+
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	impr.stats.Server = info
+}
+
+// Supports reports whether the connected LibreOffice understands the given capability
+func (impr *ImpressClient) Supports(capability string) bool {
+	return impr.GetStats().Server.Supports(capability)
+}
+
+// reconnect dials Impress again with an exponential backoff, pairs and restores the last known slide.
+// It returns the decoder of the new connection, or nil if the client has to terminate
+func (impr *ImpressClient) reconnect() *Decoder {
+	impr.setSession(SESSION_RECONNECTING)
+	// pair replaces the broken connection, which would otherwise stay open until garbage collected
+	impr.mu.Lock()
+	impr.CloseConnection()
+	impr.mu.Unlock()
+
+	u, err := url.Parse(impr.configs.remoteURL)
+	if err != nil {
+		return nil
+	}
+
+	delay := ReconnectDelay
+	for attempt := 1; attempt <= impr.configs.maxReconnects; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-impr.shutdown:
+			return nil
+		}
+
+		Logger.InfoF("Attempt no %d of %d to reconnect to impress...", attempt, impr.configs.maxReconnects)
+		rawConn, err := net.Dial("tcp", u.Host)
+		if err == nil {
+			err = impr.pair(rawConn)
+		}
+		if err == nil {
+			Logger.Notice("Reconnected to impress")
+			impr.restoreSlide()
+			impr.setSession(SESSION_RUNNING)
+
+			impr.mu.Lock()
+			defer impr.mu.Unlock()
+			if impr.isTerminated {
+				return nil
+			}
+			return impr.decoder
+		}
+		Logger.ErrorF("Failed to reconnect to impress: %v", err)
+
+		delay *= 2
+		if delay > MAX_RECONNECT_DELAY {
+			delay = MAX_RECONNECT_DELAY
+		}
+	}
 	return nil
 }
 
+func (impr *ImpressClient) restoreSlide() {
+	status := impr.GetStats().Status
+	if !status.IsRunning() {
+		return
+	}
+
+	if err := impr.getEncoder().EncodeMessage(GoToSlide{Index: status.CurrentSlide}); err != nil {
+		Logger.ErrorF("Failed to restore slide %d: %v", status.CurrentSlide, err)
+	}
+}
+
+// setSession records the state of the connection to Impress, unless the session already ended while pairing or reconnecting
+func (impr *ImpressClient) setSession(state SessionState) {
+	impr.mu.Lock()
+	if impr.isTerminated {
+		impr.mu.Unlock()
+		return
+	}
+	impr.stats.Session = state
+	impr.mu.Unlock()
+
+	impr.broadcast(SessionStatus{State: state})
+}
+
+func (impr *ImpressClient) getEncoder() *Encoder {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	return impr.encoder
+}
+
 func (impr *ImpressClient) CloseConnection() {
 	if impr.conn != nil {
 		if err := impr.conn.Close(); err != nil {
@@ -191,20 +424,27 @@ func (impr *ImpressClient) CloseConnection() {
 
 func (impr *ImpressClient) StopPresentation() {
 	if impr.presentation != nil {
+		impr.endedOwnerUUID = impr.presentation.uuid
+		stopPresentation(impr.presentation.process, impr.presentation.filePath)
+		impr.presentation = nil
+	}
+}
+
+func stopPresentation(process *os.Process, filePath string) {
+	if process != nil {
 		if runtime.GOOS == "windows" {
-			pid := strconv.Itoa(impr.presentation.command.Process.Pid)
+			pid := strconv.Itoa(process.Pid)
 			if err := exec.Command("taskkill", "/F", "/T", "/PID", pid).Run(); err != nil {
 				Logger.ErrorF("Error stopping presenation: %v", err)
 			}
 		} else {
-			if err := impr.presentation.command.Process.Signal(syscall.SIGTERM); err != nil {
+			if err := process.Signal(syscall.SIGTERM); err != nil {
 				Logger.ErrorF("Error stopping presenation: %v", err)
 			}
 		}
-		if err := os.RemoveAll(filepath.Dir(impr.presentation.filePath)); err != nil {
-			Logger.ErrorF("Failed to remove file: %v", err)
-		}
-		impr.presentation = nil
+	}
+	if err := os.RemoveAll(filepath.Dir(filePath)); err != nil {
+		Logger.ErrorF("Failed to remove file: %v", err)
 	}
 }
 
@@ -219,6 +459,15 @@ func (impr *ImpressClient) GetPresentationUUID() string {
 	}
 }
 
+// GetEndedOwnerUUID returns the last owner token of a stopped presentation, so that its owner
+// can still get what is left of the session, like its questions
+func (impr *ImpressClient) GetEndedOwnerUUID() string {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	return impr.endedOwnerUUID
+}
+
 func (impr *ImpressClient) GetPresentationPath() string {
 	impr.mu.Lock()
 	defer impr.mu.Unlock()
@@ -230,11 +479,56 @@ func (impr *ImpressClient) GetPresentationPath() string {
 	}
 }
 
+// GetRemote returns the name and PIN this client pairs with, which have to be entered in Impress on first use
+func (impr *ImpressClient) GetRemote() (string, string) {
+	return impr.configs.remoteName, impr.configs.remotePIN
+}
+
+// GetPreview returns the base64 encoded PNG preview of a slide, if Impress has sent it
+func (impr *ImpressClient) GetPreview(slide int) (string, bool) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	preview, ok := impr.previews[slide]
+	return preview, ok
+}
+
+// GetPreviewSlides returns the sorted indexes of the slides that have a preview
+func (impr *ImpressClient) GetPreviewSlides() []int {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	slides := make([]int, 0, len(impr.previews))
+	for slide := range impr.previews {
+		slides = append(slides, slide)
+	}
+	sort.Ints(slides)
+	return slides
+}
+
 func (impr *ImpressClient) GetStats() ImpressStats {
 	impr.mu.Lock()
 	defer impr.mu.Unlock()
 
-	return impr.stats
+	stats := impr.stats
+	stats.Roles = make(map[Role]int)
+	for _, role := range Roles {
+		stats.Roles[role] = 0
+	}
+	for _, controller := range impr.controllers {
+		stats.Roles[controller.role]++
+	}
+	stats.Subscribers = len(impr.subscribers)
+	return stats
+}
+
+// GetInviteRole returns the role granted by an invite token of this presentation
+func (impr *ImpressClient) GetInviteRole(token string) (Role, bool) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	role, ok := impr.invites[token]
+	return role, ok
 }
 
 func (impr *ImpressClient) IsTerminated() bool {
@@ -244,6 +538,11 @@ func (impr *ImpressClient) IsTerminated() bool {
 	return impr.isTerminated
 }
 
+// Done returns a channel that is closed once the client terminates
+func (impr *ImpressClient) Done() <-chan bool {
+	return impr.shutdown
+}
+
 func (impr *ImpressClient) HasControllerSpace() bool {
 	impr.mu.Lock()
 	defer impr.mu.Unlock()
@@ -251,9 +550,31 @@ func (impr *ImpressClient) HasControllerSpace() bool {
 	return impr.stats.Controllers < impr.configs.maxControllers
 }
 
+// RoomFullError is returned to controllers that can't join, with the limit they ran into
+func (impr *ImpressClient) RoomFullError() *Error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	return impr.roomFullErrorLocked()
+}
+
+func (impr *ImpressClient) roomFullErrorLocked() *Error {
+	return NewError(ERR_ROOM_FULL, "Slideshow has reached the maximum number of controllers").
+		WithDetail("maxControllers", impr.configs.maxControllers)
+}
+
 func (impr *ImpressClient) ListenAndServe() {
-	go impr.listenForMessages()
+	// The session may be stopped while the remote is being authorised
+	if impr.IsTerminated() {
+		Logger.Info("Impress client terminated before it started listening")
+		return
+	}
+	go impr.listenForMessages(impr.decoder)
 	go impr.serveRequests()
+	if impr.restored {
+		impr.restoreSlide()
+	}
+	impr.setSession(SESSION_RUNNING)
 	Logger.Info("Impress client started listening & serving")
 
 	stats := impr.GetStats()
@@ -267,24 +588,36 @@ func (impr *ImpressClient) ListenAndServe() {
 	}
 }
 
+// Terminate ends the session without a more specific reason
 func (impr *ImpressClient) Terminate() {
-	impr.mu.Lock()
-	defer impr.mu.Unlock()
+	impr.End(END_TERMINATED)
+}
 
+func (impr *ImpressClient) terminateLocked(reason EndReason) {
 	if !impr.isTerminated {
 		impr.isTerminated = true
+		impr.summary = impr.summarizeLocked(reason)
+		impr.stats.Session = SESSION_TERMINATED
 
-		Logger.Notice("Impress client received terminate signal. Shutting down")
+		Logger.NoticeF("Impress client received terminate signal (%s). Shutting down", reason)
 		if impr.ticker != nil {
 			impr.ticker.Stop()
 		}
+		impr.stopControlTimersLocked()
 		for _, controller := range impr.controllers {
-			controller.send <- []string{SLIDE_SHOW_FINISHED}
+			controller.send <- SlideStatus{Status: SlideShowFinished{}}
+			controller.send <- SessionEnded{Reason: reason}
+			controller.send <- disconnect{Code: reason.closeCode(), Reason: string(reason)}
 			close(controller.send)
 		}
+		impr.publishLocked(SlideStatus{Status: SlideShowFinished{}})
+		impr.publishLocked(SessionEnded{Reason: reason})
+		impr.publishLocked(SessionStatus{State: SESSION_TERMINATED})
+		impr.closeSubscribersLocked()
 		close(impr.shutdown)
 		impr.CloseConnection()
 		impr.StopPresentation()
+		impr.stateChangedLocked()
 	}
 }
 
@@ -298,19 +631,47 @@ func (impr *ImpressClient) handleRegistrations() {
 				impr.controllers = append(impr.controllers, controller)
 				if controller.IsOwner() {
 					Logger.Info("Owner joined the presentation")
-					impr.ticker.Stop()
+					if impr.ticker != nil {
+						impr.ticker.Stop()
+					}
 					impr.stats.IsOwnerPresent = true
 				}
 				impr.stats.Controllers++
+				impr.resumeTokens[controller.resumeToken] = controller.participant.ID
+				controller.joined <- true
 			} else {
+				// The room filled up since the controller was let in, it never joins the list so its channel is closed here
 				Logger.Info("The maximum number of controllers was reached")
+				controller.joined <- false
+				controller.send <- disconnect{Code: websocket.CloseTryAgainLater, Reason: string(ERR_ROOM_FULL), Error: impr.roomFullErrorLocked()}
+				close(controller.send)
+				impr.mu.Unlock()
+				continue
 			}
 
-			currentStatus := impr.stats.Status
-			if len(currentStatus) > 0 && currentStatus[0] != SLIDE_SHOW_FINISHED {
-				currentStatus = append(currentStatus, impr.previews[currentStatus[2]])
+			controller.send <- ControllerInfo{ID: controller.participant.ID, Name: controller.participant.Name, Role: controller.role, ResumeToken: controller.resumeToken}
+			impr.sendRosterLocked()
+			if controller.IsOwner() && len(impr.controlRequests) > 0 {
+				impr.sendControlRequestsLocked()
+			}
+			if questions := impr.openQuestionsLocked(); len(questions.Questions) > 0 {
+				controller.send <- questions
+			}
+			if impr.poll != nil && impr.poll.IsOpen {
+				controller.send <- impr.poll.copy()
+			}
+			if impr.stats.Session != SESSION_RUNNING {
+				controller.send <- SessionStatus{State: impr.stats.Session}
+			}
+			status := impr.stats.Status
+			if message := status.Message(); message != nil {
+				controller.send <- SlideStatus{Status: message, Preview: impr.previews[status.CurrentSlide]}
+			}
+			if controller.IsOwner() && status.IsRunning() {
+				if notes, ok := impr.notes[status.CurrentSlide]; ok {
+					controller.send <- Notes{Slide: status.CurrentSlide, Notes: notes}
+				}
 			}
-			controller.send <- currentStatus
 
 			impr.mu.Unlock()
 		case controller := <-impr.unregister:
@@ -319,12 +680,20 @@ func (impr *ImpressClient) handleRegistrations() {
 					impr.mu.Lock()
 
 					impr.controllers = append(impr.controllers[:i], impr.controllers[i+1:]...)
+					// Terminate already closed the channels of every controller
+					if impr.isTerminated {
+						impr.stats.Controllers--
+						impr.mu.Unlock()
+						break
+					}
 					if contr.IsOwner() {
 						Logger.InfoF("Presentation owner has left. Waiting %d seconds for him to come back...", impr.configs.ownerTimeout)
 						impr.ticker = impr.waitForOwner(time.Duration(impr.configs.ownerTimeout) * time.Second)
 						impr.stats.IsOwnerPresent = false
 					}
 					impr.stats.Controllers--
+					impr.forgetControlLocked(contr)
+					impr.sendRosterLocked()
 
 					impr.mu.Unlock()
 					close(contr.send)
@@ -342,7 +711,7 @@ func (impr *ImpressClient) waitForOwner(duration time.Duration) *time.Ticker {
 	go func(shutdown chan bool) {
 		select {
 		case <-ticker.C:
-			impr.Terminate()
+			impr.End(END_OWNER_TIMEOUT)
 		case <-shutdown:
 			return
 		}
@@ -350,141 +719,366 @@ func (impr *ImpressClient) waitForOwner(duration time.Duration) *time.Ticker {
 	return ticker
 }
 
-func (impr *ImpressClient) listenForMessages() {
+func (impr *ImpressClient) listenForMessages(decoder *Decoder) {
 	for {
-		message, err := readMessage(impr.conn)
+		message, err := decoder.DecodeMessage()
+		if protocolErr, ok := err.(*ProtocolError); ok {
+			Logger.WarningF("Dropped Impress message: %v", protocolErr)
+			continue
+		}
 		if err != nil {
-			if !impr.isTerminated {
-				Logger.ErrorF("Error reading Impress message: %v", err)
+			if impr.IsTerminated() {
+				return
+			}
+			Logger.ErrorF("Error reading Impress message: %v", err)
+			if decoder = impr.reconnect(); decoder == nil {
 				Logger.Critical("Impress client stopped listening for messages")
+				impr.End(END_IMPRESS_CRASHED)
+				return
 			}
-			break
+			continue
 		}
-		if ok := checkValidMessage(message); ok {
-			impr.messages <- message
+
+		select {
+		case impr.messages <- message:
+		case <-impr.shutdown:
+			return
 		}
 	}
 }
 
-func checkValidMessage(message []string) bool {
-	switch message[0] {
-	case PAIRED, VALIDATING, SLIDE_SHOW_INFO, SLIDE_SHOW_FINISHED, SLIDE_SHOW_STARTED, SLIDE_UPDATED, SLIDE_PREVIEW:
-		return true
-	default:
-		return false
-	}
+// impressRequest is a command queued for Impress, with the controller that sent it. Commands executed over HTTP have none
+type impressRequest struct {
+	command ProtocolMessage
+	from    *ImpressController
 }
 
 func (impr *ImpressClient) serveRequests() {
+	reactionsTicker := time.NewTicker(REACTIONS_PERIOD)
+	defer reactionsTicker.Stop()
+
 	for {
 		select {
 		case message := <-impr.messages:
-			switch message[0] {
-			case PAIRED, VALIDATING:
-			case SLIDE_PREVIEW:
-				impr.previews[message[1]] = strings.Join(message[2:], "")
-				currentStatus := impr.stats.Status
-				if len(currentStatus) > 0 && currentStatus[0] != SLIDE_SHOW_FINISHED && message[1] == currentStatus[2] {
-					currentStatus = append(currentStatus, impr.previews[currentStatus[2]])
-					for _, controller := range impr.controllers {
-						controller.send <- currentStatus
-					}
+			switch message := message.(type) {
+			case Preview:
+				impr.mu.Lock()
+				impr.previews[message.Slide] = message.Image
+				status := impr.stats.Status
+				impr.mu.Unlock()
+				if status.IsRunning() && status.CurrentSlide == message.Slide {
+					impr.broadcast(SlideStatus{Status: status.Message(), Preview: message.Image})
 				}
-			case SLIDE_SHOW_INFO:
-				impr.stats.Name = message[1]
-			case SLIDE_SHOW_FINISHED:
-				impr.updateStatus(message)
-				for _, controller := range impr.controllers {
-					controller.send <- message
+			case Notes:
+				impr.mu.Lock()
+				impr.notes[message.Slide] = message.Notes
+				status := impr.stats.Status
+				impr.mu.Unlock()
+				if status.IsRunning() && status.CurrentSlide == message.Slide {
+					impr.sendNotesToOwner(message.Slide)
 				}
-			case SLIDE_SHOW_STARTED:
+			case ServerVersion:
+				impr.setServerInfo(message)
+			case SlideShowInfo:
+				impr.mu.Lock()
+				impr.stats.Name = message.Title
+				impr.mu.Unlock()
+			case SlideShowFinished:
 				impr.updateStatus(message)
-				message = append(message, impr.previews[message[2]])
-				for _, controller := range impr.controllers {
-					controller.send <- message
-				}
-			case SLIDE_UPDATED:
+				impr.broadcast(SlideStatus{Status: message})
+			case SlideShowStarted:
 				impr.updateStatus(message)
-				message = append(message, impr.previews[message[1]])
-				for _, controller := range impr.controllers {
-					controller.send <- message
+				impr.broadcast(SlideStatus{Status: message, Preview: impr.getPreview(message.Current)})
+				impr.sendNotesToOwner(message.Current)
+			case SlideUpdated:
+				impr.updateStatus(message)
+				impr.broadcast(SlideStatus{Status: message, Preview: impr.getPreview(message.Current)})
+				impr.sendNotesToOwner(message.Current)
+			}
+		case queued := <-impr.requests:
+			request := queued.command
+			if impr.GetStats().Session == SESSION_RECONNECTING {
+				if _, ok := request.(PresentationStop); ok {
+					impr.End(END_STOPPED_BY_OWNER)
+				} else {
+					Logger.InfoF("Dropped %s request while reconnecting to impress", request.Command())
 				}
-
+				break
 			}
-		case request := <-impr.requests:
-			if request[0] == TRANSITION_NEXT || request[0] == TRANSITION_PREVIOUS {
-				status := impr.GetStats().Status
-				if len(status) > 0 && status[0] == SLIDE_SHOW_STARTED {
-					currentSlide, _ := strconv.Atoi(status[2])
-					maxSlide, _ := strconv.Atoi(status[1])
-					if request[0] == TRANSITION_PREVIOUS && currentSlide == 0 {
-						break
-					} else if request[0] == TRANSITION_NEXT && currentSlide == maxSlide-1 {
-						break
-					}
+			if isPointerRequest(request) {
+				if !impr.Supports(CAPABILITY_POINTER) {
+					break
 				}
+				impr.sharePointer(queued.from, request)
+			} else if impr.isOutOfBounds(request) {
+				break
 			}
-			err := sendRequest(request, impr.conn)
+			err := impr.getEncoder().EncodeMessage(request)
 			if err != nil {
 				Logger.ErrorF("Error writing Impress request: %v", err)
-				Logger.Critical("Impress client stopped serving controller requests")
+				// The listener notices the closed connection and reconnects
+				impr.closeBrokenConnection()
 				break
 			}
-			if request[0] == PRESENTATION_STOP {
-				impr.Terminate()
+			if _, ok := request.(PresentationStop); ok {
+				impr.End(END_STOPPED_BY_OWNER)
 				break
 			}
+		case <-reactionsTicker.C:
+			impr.flushReactions()
 		case <-impr.shutdown:
 			return
 		}
 	}
 }
 
-func (impr *ImpressClient) updateStatus(messages []string) {
+func (impr *ImpressClient) broadcast(message Message) {
 	impr.mu.Lock()
 	defer impr.mu.Unlock()
 
-	switch messages[0] {
-	case SLIDE_SHOW_FINISHED:
-		impr.stats.Status = []string{SLIDE_SHOW_FINISHED}
-	case SLIDE_SHOW_STARTED:
-		impr.stats.Status = []string{SLIDE_SHOW_STARTED, messages[1], messages[2]}
-	case SLIDE_UPDATED:
-		if len(impr.stats.Status) > 0 && impr.stats.Status[0] == SLIDE_SHOW_STARTED {
-			impr.stats.Status[2] = messages[1]
-		} else {
-			impr.stats.Status = []string{SLIDE_UPDATED, messages[1]}
+	// Events read from Impress before the session ended are still handled, after Terminate closed every channel
+	if impr.isTerminated {
+		return
+	}
+	for _, controller := range impr.controllers {
+		controller.offer(message)
+	}
+	impr.publishLocked(message)
+}
+
+func (impr *ImpressClient) getPreview(slide int) string {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	return impr.previews[slide]
+}
+
+func (impr *ImpressClient) sendNotesToOwner(slide int) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	notes, ok := impr.notes[slide]
+	if !ok || impr.isTerminated {
+		return
+	}
+	for _, controller := range impr.controllers {
+		if controller.IsOwner() {
+			controller.send <- Notes{Slide: slide, Notes: notes}
 		}
 	}
-	// Logger.InfoF("Presentation status updated: %s", messages)
 }
 
-func readMessage(conn net.Conn) ([]string, error) {
-	reader := bufio.NewReader(conn)
-	messages := make([]string, 0)
-	for {
-		bytes, _, err := reader.ReadLine()
-		if err != nil {
-			return nil, err
+// sharePointer shows the pointer of a controller to all the others, the owner included when a co-presenter points
+func (impr *ImpressClient) sharePointer(from *ImpressController, request ProtocolMessage) {
+	if !impr.configs.sharePointer {
+		return
+	}
+
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return
+	}
+	for _, controller := range impr.controllers {
+		if controller != from {
+			controller.offer(request)
 		}
+	}
+}
 
-		message := string(bytes)
-		if message == "" {
-			break
+func (impr *ImpressClient) controllerRole(controller *ImpressController) Role {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	return controller.role
+}
+
+// isRegisteredLocked tells whether the controller joined and hasn't left yet, which is when its channel can be sent to
+func (impr *ImpressClient) isRegisteredLocked(controller *ImpressController) bool {
+	for _, registered := range impr.controllers {
+		if registered == controller {
+			return true
 		}
+	}
+	return false
+}
+
+// createInvite generates a token granting the role to the controllers connecting with it, until the presentation ends
+func (impr *ImpressClient) createInvite(from *ImpressController, role Role) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
 
-		messages = append(messages, message)
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	if !impr.isRegisteredLocked(from) {
+		return ErrNotJoined
 	}
-	return messages, nil
+	token := newToken(16)
+	impr.invites[token] = role
+	Logger.InfoF("Created %s invite", role)
+	impr.stateChangedLocked()
+
+	from.send <- InviteCreated{Token: token, Role: role}
+	return nil
 }
 
-func sendRequest(messages []string, conn net.Conn) error {
-	writer := bufio.NewWriter(conn)
-	formattedMessage := strings.Join(messages, "\n") + "\n\n"
-	_, err := writer.WriteString(formattedMessage)
-	if err != nil {
-		return err
+// transferOwnership hands the presentation to another controller. It gets a fresh owner token,
+// which revokes the old one, so every other controller connected as owner becomes a viewer
+func (impr *ImpressClient) transferOwnership(from *ImpressController, controllerID string) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	if !from.IsOwner() {
+		return NewError(ERR_NOT_OWNER, "Only the owner can control the presentation")
+	}
+	if from.participant.ID == controllerID {
+		return NewError(ERR_CONFLICT, "Controller already owns the presentation")
+	}
+	var to *ImpressController
+	for _, controller := range impr.controllers {
+		if controller.participant.ID == controllerID {
+			to = controller
+		}
+	}
+	if to == nil || impr.presentation == nil {
+		return NewError(ERR_NOT_FOUND, "Controller not found")
+	}
+
+	impr.presentation.uuid = newToken(16)
+	impr.stateChangedLocked()
+	for _, controller := range impr.controllers {
+		if controller == to {
+			controller.role = ROLE_OWNER
+		} else if controller.IsOwner() {
+			controller.role = ROLE_VIEWER
+		}
+	}
+	Logger.InfoF("Presentation ownership was transferred to controller %s", controllerID)
+
+	to.send <- OwnershipGranted{OwnerUUID: impr.presentation.uuid}
+	for _, controller := range impr.controllers {
+		controller.send <- OwnerChanged{ControllerID: controllerID}
+	}
+	status := impr.stats.Status
+	if notes, ok := impr.notes[status.CurrentSlide]; ok && status.IsRunning() {
+		to.send <- Notes{Slide: status.CurrentSlide, Notes: notes}
+	}
+	impr.sendRosterLocked()
+	if len(impr.controlRequests) > 0 {
+		impr.sendControlRequestsLocked()
 	}
-	writer.Flush()
 	return nil
 }
+
+// GetParticipants returns the participants of the connected controllers, in the order they joined
+func (impr *ImpressClient) GetParticipants() []Participant {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	return impr.participantsLocked()
+}
+
+// ResumeParticipant returns the participant ID a resume token was handed out with
+func (impr *ImpressClient) ResumeParticipant(token string) (string, bool) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	id, ok := impr.resumeTokens[token]
+	return id, ok
+}
+
+// IsParticipantConnected tells whether a controller with the participant ID is connected
+func (impr *ImpressClient) IsParticipantConnected(id string) bool {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	for _, controller := range impr.controllers {
+		if controller.participant.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (impr *ImpressClient) participantsLocked() []Participant {
+	participants := make([]Participant, 0, len(impr.controllers))
+	for _, controller := range impr.controllers {
+		participant := controller.participant
+		participant.Role = controller.role
+		participants = append(participants, participant)
+	}
+	return participants
+}
+
+func (impr *ImpressClient) sendRosterLocked() {
+	roster := Roster{Participants: impr.participantsLocked()}
+	for _, controller := range impr.controllers {
+		if controller.IsOwner() {
+			controller.send <- roster
+		}
+	}
+}
+
+func (impr *ImpressClient) closeBrokenConnection() {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.conn != nil {
+		impr.conn.Close()
+	}
+}
+
+func isPointerRequest(request ProtocolMessage) bool {
+	switch request.(type) {
+	case PointerStarted, PointerCoordination, PointerDismissed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Impress ignores moves past the first or last slide, so they are dropped before reaching it
+func (impr *ImpressClient) isOutOfBounds(request ProtocolMessage) bool {
+	status := impr.GetStats().Status
+	if !status.IsRunning() || status.TotalSlides == 0 {
+		return false
+	}
+
+	switch request := request.(type) {
+	case TransitionPrevious:
+		return status.CurrentSlide == 0
+	case TransitionNext:
+		return status.CurrentSlide == status.TotalSlides-1
+	case GoToSlide:
+		return request.Index >= status.TotalSlides
+	default:
+		return false
+	}
+}
+
+func (impr *ImpressClient) updateStatus(message ProtocolMessage) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	switch message := message.(type) {
+	case SlideShowFinished:
+		impr.stats.Status = SlideShowStatus{State: STATE_FINISHED}
+	case SlideShowStarted:
+		impr.stats.Status = SlideShowStatus{State: STATE_RUNNING, TotalSlides: message.Total, CurrentSlide: message.Current}
+		impr.visited[message.Current] = true
+	case SlideUpdated:
+		if !impr.stats.Status.IsRunning() {
+			impr.stats.Status = SlideShowStatus{State: STATE_RUNNING, TotalSlides: 0}
+		}
+		impr.stats.Status.CurrentSlide = message.Current
+		impr.visited[message.Current] = true
+	}
+	close(impr.statusChanged)
+	impr.statusChanged = make(chan struct{})
+	impr.stateChangedLocked()
+}
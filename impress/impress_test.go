@@ -0,0 +1,225 @@
+package impress_test
+
+import (
+	ioutil "io/ioutil"
+	os "os"
+	filepath "path/filepath"
+	reflect "reflect"
+	testing "testing"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	impresstest "github.com/DanInci/raspi-projector-backend/impress/impresstest"
+	log "github.com/apsdehal/go-logger"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestMain(m *testing.M) {
+	logger, err := log.New("impress-test", 0, ioutil.Discard)
+	if err != nil {
+		panic(err)
+	}
+	impress.Logger = logger
+	impress.OfficeLauncher = impresstest.FakeOffice
+	impress.PairingDelay = 0
+	impress.ReconnectDelay = 10 * time.Millisecond
+	os.Exit(m.Run())
+}
+
+func newTestClient(t *testing.T, server *impresstest.Server) *impress.ImpressClient {
+	impress.Configure("soffice", "TestRemote", "1234", 10, 60, false, 3)
+	client := impress.NewClient(server.URL, "")
+
+	deckPath := filepath.Join(t.TempDir(), "uploads", "deck.pptx")
+	if err := os.MkdirAll(filepath.Dir(deckPath), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartPresentation("owner", deckPath); err != nil {
+		t.Fatalf("StartPresentation: %v", err)
+	}
+	t.Cleanup(client.Terminate)
+	return client
+}
+
+func waitForStatus(t *testing.T, client *impress.ImpressClient, expected impress.SlideShowStatus) {
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		if client.GetStats().Status == expected {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("status is %v, expected %v", client.GetStats().Status, expected)
+}
+
+func TestOpenConnectionPairs(t *testing.T) {
+	server := impresstest.NewServer()
+	defer server.Close()
+	client := newTestClient(t, server)
+
+	if err := client.OpenConnection(); err != nil {
+		t.Fatalf("OpenConnection: %v", err)
+	}
+	expected := []string{impress.PAIR_WITH_SERVER, "TestRemote", "1234"}
+	if pairing := server.Pairing(); !reflect.DeepEqual(pairing, expected) {
+		t.Errorf("pairing message is %v, expected %v", pairing, expected)
+	}
+}
+
+func TestOpenConnectionWaitsForPIN(t *testing.T) {
+	server := impresstest.NewServer()
+	server.RequirePIN = true
+	defer server.Close()
+	client := newTestClient(t, server)
+
+	result := make(chan error)
+	go func() {
+		result <- client.OpenConnection()
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("OpenConnection returned before authorisation: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	server.Authorise()
+	if err := <-result; err != nil {
+		t.Fatalf("OpenConnection: %v", err)
+	}
+}
+
+func TestStatusFollowsSlideShow(t *testing.T) {
+	server := impresstest.NewServer()
+	defer server.Close()
+	client := newTestClient(t, server)
+	if err := client.OpenConnection(); err != nil {
+		t.Fatalf("OpenConnection: %v", err)
+	}
+	client.ListenAndServe()
+
+	server.StartSlideShow(5, 0)
+	waitForStatus(t, client, impress.SlideShowStatus{State: impress.STATE_RUNNING, TotalSlides: 5, CurrentSlide: 0})
+
+	server.UpdateSlide(3)
+	waitForStatus(t, client, impress.SlideShowStatus{State: impress.STATE_RUNNING, TotalSlides: 5, CurrentSlide: 3})
+
+	// A preview and the following update arriving in the same read must both be handled
+	server.SendRaw([]byte("slide_preview\n4\ncHJldmlldw==\n\nslide_updated\n4\n\n"))
+	waitForStatus(t, client, impress.SlideShowStatus{State: impress.STATE_RUNNING, TotalSlides: 5, CurrentSlide: 4})
+
+	// Malformed messages are dropped without tearing down the connection
+	server.Send(impress.SLIDE_UPDATED, "not a number")
+	server.Send(impress.SLIDE_SHOW_STARTED, "5")
+	server.UpdateSlide(2)
+	waitForStatus(t, client, impress.SlideShowStatus{State: impress.STATE_RUNNING, TotalSlides: 5, CurrentSlide: 2})
+
+	server.FinishSlideShow()
+	waitForStatus(t, client, impress.SlideShowStatus{State: impress.STATE_FINISHED})
+}
+
+func TestTerminate(t *testing.T) {
+	server := impresstest.NewServer()
+	defer server.Close()
+	client := newTestClient(t, server)
+	if err := client.OpenConnection(); err != nil {
+		t.Fatalf("OpenConnection: %v", err)
+	}
+	client.ListenAndServe()
+
+	client.Terminate()
+	if !client.IsTerminated() {
+		t.Error("client is not terminated")
+	}
+	if uuid := client.GetPresentationUUID(); uuid != "" {
+		t.Errorf("presentation is still running with uuid %s", uuid)
+	}
+}
+
+func TestReconnectRestoresSlide(t *testing.T) {
+	server := impresstest.NewServer()
+	defer server.Close()
+	client := newTestClient(t, server)
+	if err := client.OpenConnection(); err != nil {
+		t.Fatalf("OpenConnection: %v", err)
+	}
+	server.WaitConnected(testTimeout)
+	client.ListenAndServe()
+
+	server.StartSlideShow(5, 0)
+	server.UpdateSlide(3)
+	waitForStatus(t, client, impress.SlideShowStatus{State: impress.STATE_RUNNING, TotalSlides: 5, CurrentSlide: 3})
+
+	server.Drop()
+	if err := server.WaitConnected(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	request, err := server.NextRequest(testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != (impress.GoToSlide{Index: 3}) {
+		t.Errorf("expected the last slide to be restored, got %v", request)
+	}
+
+	deadline := time.Now().Add(testTimeout)
+	for client.GetStats().Session != impress.SESSION_RUNNING && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if session := client.GetStats().Session; session != impress.SESSION_RUNNING {
+		t.Errorf("session is %s after reconnecting", session)
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	server := impresstest.NewServer()
+	client := newTestClient(t, server)
+	if err := client.OpenConnection(); err != nil {
+		t.Fatalf("OpenConnection: %v", err)
+	}
+	client.ListenAndServe()
+
+	server.Close()
+	deadline := time.Now().Add(testTimeout)
+	for !client.IsTerminated() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !client.IsTerminated() {
+		t.Error("client was not terminated after exhausting its reconnect attempts")
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	cases := []struct {
+		version   string
+		infoFirst bool
+		pointer   bool
+	}{
+		{version: "7.3.0.3", pointer: true},
+		{version: "6.4.7.2", infoFirst: true, pointer: true},
+		{version: "4.3.3.2", pointer: false},
+		{version: "", pointer: false},
+	}
+	for _, c := range cases {
+		server := impresstest.NewServer()
+		server.Version = c.version
+		server.InfoFirst = c.infoFirst
+		client := newTestClient(t, server)
+		if err := client.OpenConnection(); err != nil {
+			t.Fatalf("OpenConnection with version %q: %v", c.version, err)
+		}
+		client.ListenAndServe()
+
+		deadline := time.Now().Add(testTimeout)
+		for client.GetStats().Server.Version != c.version && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if version := client.GetStats().Server.Version; version != c.version {
+			t.Errorf("recorded version %q, expected %q", version, c.version)
+		}
+		if client.Supports(impress.CAPABILITY_POINTER) != c.pointer {
+			t.Errorf("LibreOffice %q pointer support should be %v", c.version, c.pointer)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,35 @@
+package impresstest
+
+import (
+	zip "archive/zip"
+	bytes "bytes"
+	fmt "fmt"
+)
+
+// Deck builds a minimal .pptx deck with a slide per title. Empty titles make slides without a title placeholder.
+// The slide files are numbered in reverse, so that only the presentation part gives their order
+func Deck(titles ...string) []byte {
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+	write := func(name string, content string) {
+		file, _ := archive.Create(name)
+		file.Write([]byte(content))
+	}
+
+	slideIDs, relationships := "", ""
+	for i, title := range titles {
+		number := len(titles) - i
+		slideIDs += fmt.Sprintf(`<p:sldId id="%d" r:id="rId%d"/>`, 256+i, number)
+		relationships += fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide%d.xml"/>`, number, number)
+
+		shape := `<p:sp><p:nvSpPr><p:cNvPr id="3" name="Body"/><p:cNvSpPr/><p:nvPr><p:ph idx="1"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>Body</a:t></a:r></a:p></p:txBody></p:sp>`
+		if title != "" {
+			shape = fmt.Sprintf(`<p:sp><p:nvSpPr><p:cNvPr id="2" name="Title"/><p:cNvSpPr/><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>%s</a:t></a:r></a:p></p:txBody></p:sp>`, title) + shape
+		}
+		write(fmt.Sprintf("ppt/slides/slide%d.xml", number), `<?xml version="1.0" encoding="UTF-8"?><p:sld xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><p:cSld><p:spTree>`+shape+`</p:spTree></p:cSld></p:sld>`)
+	}
+	write("ppt/presentation.xml", `<?xml version="1.0" encoding="UTF-8"?><p:presentation xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><p:sldIdLst>`+slideIDs+`</p:sldIdLst></p:presentation>`)
+	write("ppt/_rels/presentation.xml.rels", `<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+relationships+`</Relationships>`)
+	archive.Close()
+	return buffer.Bytes()
+}
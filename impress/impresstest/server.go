@@ -0,0 +1,298 @@
+// Package impresstest provides an in-process stand-in for the LibreOffice Impress Remote server
+package impresstest
+
+import (
+	errors "errors"
+	fmt "fmt"
+	net "net"
+	os "os"
+	exec "os/exec"
+	signal "os/signal"
+	sync "sync"
+	syscall "syscall"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+const fakeOfficeEnv = "IMPRESSTEST_FAKE_OFFICE"
+
+// A test binary importing this package turns into a fake soffice process when re-executed by FakeOffice
+func init() {
+	if os.Getenv(fakeOfficeEnv) != "1" {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-c:
+	case <-time.After(5 * time.Minute):
+	}
+	os.Exit(0)
+}
+
+// FakeOffice can be used as impress.OfficeLauncher. It starts a process that idles until it is terminated
+func FakeOffice(librePath string, profileDirectory string, path string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), fakeOfficeEnv+"=1")
+	return cmd
+}
+
+type Server struct {
+	URL string
+
+	// Reply with LO_SERVER_VALIDATING_PIN and wait for Authorise before pairing
+	RequirePIN bool
+	// LibreOffice version announced with LO_SERVER_INFO after pairing. Nothing is announced if empty
+	Version string
+	// Announce the version before the pairing reply instead of after it
+	InfoFirst bool
+
+	listener   net.Listener
+	conn       net.Conn
+	encoder    *impress.Encoder
+	pairing    []string
+	total      int
+	current    int
+	authorised chan bool
+	done       chan bool
+	connected  chan bool
+	requests   chan impress.ProtocolMessage
+	closed     bool
+	mu         sync.Mutex
+}
+
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("impresstest: failed to listen: %v", err))
+	}
+	s := &Server{
+		URL:        "ws://" + listener.Addr().String(),
+		Version:    "7.3.0.3",
+		listener:   listener,
+		authorised: make(chan bool),
+		done:       make(chan bool),
+		connected:  make(chan bool, 1),
+		requests:   make(chan impress.ProtocolMessage, 100),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+		s.listener.Close()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}
+}
+
+// Drop closes the current remote connection without stopping the server
+func (s *Server) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+		s.encoder = nil
+	}
+}
+
+// Authorise simulates the user entering the PIN in LibreOffice
+func (s *Server) Authorise() {
+	s.authorised <- true
+}
+
+// WaitConnected blocks until a remote has been paired
+func (s *Server) WaitConnected(timeout time.Duration) error {
+	select {
+	case <-s.connected:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("impresstest: no remote paired")
+	}
+}
+
+// Pairing returns the LO_SERVER_CLIENT_PAIR message received from the last remote
+func (s *Server) Pairing() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pairing
+}
+
+// NextRequest returns the next command received from the remote
+func (s *Server) NextRequest(timeout time.Duration) (impress.ProtocolMessage, error) {
+	select {
+	case request := <-s.requests:
+		return request, nil
+	case <-time.After(timeout):
+		return nil, errors.New("impresstest: no request received")
+	}
+}
+
+func (s *Server) StartSlideShow(total int, current int) error {
+	s.mu.Lock()
+	s.total = total
+	s.current = current
+	s.mu.Unlock()
+
+	return s.SendMessage(impress.SlideShowStarted{Total: total, Current: current})
+}
+
+func (s *Server) UpdateSlide(current int) error {
+	s.mu.Lock()
+	s.current = current
+	s.mu.Unlock()
+
+	return s.SendMessage(impress.SlideUpdated{Current: current})
+}
+
+func (s *Server) SendPreview(slide int, preview string) error {
+	return s.SendMessage(impress.Preview{Slide: slide, Image: preview})
+}
+
+func (s *Server) SendNotes(slide int, notes string) error {
+	return s.SendMessage(impress.Notes{Slide: slide, Notes: notes})
+}
+
+func (s *Server) FinishSlideShow() error {
+	s.mu.Lock()
+	s.total = 0
+	s.mu.Unlock()
+
+	return s.SendMessage(impress.SlideShowFinished{})
+}
+
+func (s *Server) SendMessage(message impress.ProtocolMessage) error {
+	return s.Send(message.Lines()...)
+}
+
+// Send writes the lines of a protocol message to the paired remote, without validating them
+func (s *Server) Send(message ...string) error {
+	s.mu.Lock()
+	encoder := s.encoder
+	s.mu.Unlock()
+	if encoder == nil {
+		return errors.New("impresstest: no remote connected")
+	}
+
+	return encoder.Encode(message)
+}
+
+// SendRaw writes bytes to the paired remote as they are, to exercise the framing
+func (s *Server) SendRaw(data []byte) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return errors.New("impresstest: no remote connected")
+	}
+
+	_, err := conn.Write(data)
+	return err
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	decoder := impress.NewDecoder(conn, impress.DEFAULT_MAX_MESSAGE_SIZE)
+	pairing, err := decoder.Decode()
+	if _, ok := parse(pairing, err).(impress.PairRequest); !ok {
+		conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	s.pairing = pairing
+	s.conn = conn
+	s.encoder = impress.NewEncoder(conn)
+	s.mu.Unlock()
+
+	if s.InfoFirst && s.Version != "" {
+		s.SendMessage(impress.ServerVersion{Version: s.Version})
+	}
+	if s.RequirePIN {
+		if err := s.SendMessage(impress.ValidatingPIN{}); err != nil {
+			return
+		}
+		select {
+		case <-s.authorised:
+		case <-s.done:
+			return
+		}
+	}
+	if err := s.SendMessage(impress.Paired{}); err != nil {
+		return
+	}
+	if !s.InfoFirst && s.Version != "" {
+		s.SendMessage(impress.ServerVersion{Version: s.Version})
+	}
+	select {
+	case s.connected <- true:
+	default:
+	}
+
+	for {
+		request, err := decoder.DecodeMessage()
+		if _, ok := err.(*impress.ProtocolError); ok {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		s.requests <- request
+		s.react(request)
+	}
+}
+
+// react answers commands the way LibreOffice does while a slideshow is running
+func (s *Server) react(request impress.ProtocolMessage) {
+	s.mu.Lock()
+	total, current := s.total, s.current
+	s.mu.Unlock()
+	if total == 0 {
+		return
+	}
+
+	switch request := request.(type) {
+	case impress.TransitionNext:
+		if current < total-1 {
+			s.UpdateSlide(current + 1)
+		}
+	case impress.TransitionPrevious:
+		if current > 0 {
+			s.UpdateSlide(current - 1)
+		}
+	case impress.GoToSlide:
+		if request.Index < total {
+			s.UpdateSlide(request.Index)
+		}
+	case impress.PresentationStop:
+		s.FinishSlideShow()
+	}
+}
+
+func parse(lines []string, err error) impress.ProtocolMessage {
+	if err != nil {
+		return nil
+	}
+	message, _ := impress.ParseMessage(lines)
+	return message
+}
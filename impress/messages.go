@@ -0,0 +1,262 @@
+package impress
+
+import (
+	strconv "strconv"
+	strings "strings"
+)
+
+// Message is anything exchanged with Impress or pushed to the controllers
+type Message interface {
+	Command() string
+}
+
+// ProtocolMessage is a message of the Impress Remote protocol, either an event from Impress or a command for it
+type ProtocolMessage interface {
+	Message
+	Lines() []string
+}
+
+type SlideShowState string
+
+const (
+	STATE_IDLE     SlideShowState = "idle"
+	STATE_RUNNING  SlideShowState = "running"
+	STATE_FINISHED SlideShowState = "finished"
+)
+
+// TotalSlides is 0 while unknown, which happens when the remote joins an already running slideshow
+type SlideShowStatus struct {
+	State        SlideShowState
+	TotalSlides  int
+	CurrentSlide int
+}
+
+// Events sent by Impress
+
+type Paired struct{}
+type ValidatingPIN struct{}
+type ServerVersion struct{ Version string }
+type SlideShowInfo struct{ Title string }
+type SlideShowStarted struct{ Total, Current int }
+type SlideShowFinished struct{}
+type SlideUpdated struct{ Current int }
+type Preview struct {
+	Slide int
+	Image string // base64 encoded PNG
+}
+type Notes struct {
+	Slide int
+	Notes string
+}
+
+// Commands sent to Impress
+
+type PairRequest struct{ Name, PIN string }
+type TransitionNext struct{}
+type TransitionPrevious struct{}
+type GoToSlide struct{ Index int }
+type PresentationBlankScreen struct{}
+type PresentationResume struct{}
+type PresentationStart struct{}
+type PresentationStop struct{}
+type PointerStarted struct{ X, Y float64 }
+type PointerCoordination struct{ X, Y float64 }
+type PointerDismissed struct{}
+
+func (Paired) Command() string                  { return PAIRED }
+func (ValidatingPIN) Command() string           { return VALIDATING }
+func (ServerVersion) Command() string           { return SERVER_INFO }
+func (SlideShowInfo) Command() string           { return SLIDE_SHOW_INFO }
+func (SlideShowStarted) Command() string        { return SLIDE_SHOW_STARTED }
+func (SlideShowFinished) Command() string       { return SLIDE_SHOW_FINISHED }
+func (SlideUpdated) Command() string            { return SLIDE_UPDATED }
+func (Preview) Command() string                 { return SLIDE_PREVIEW }
+func (Notes) Command() string                   { return SLIDE_NOTES }
+func (PairRequest) Command() string             { return PAIR_WITH_SERVER }
+func (TransitionNext) Command() string          { return TRANSITION_NEXT }
+func (TransitionPrevious) Command() string      { return TRANSITION_PREVIOUS }
+func (GoToSlide) Command() string               { return GO_TO_SLIDE }
+func (PresentationBlankScreen) Command() string { return PRESENTATION_BLANK_SCREEN }
+func (PresentationResume) Command() string      { return PRESENTATION_RESUME }
+func (PresentationStart) Command() string       { return PRESENTATION_START }
+func (PresentationStop) Command() string        { return PRESENTATION_STOP }
+func (PointerStarted) Command() string          { return POINTER_STARTED }
+func (PointerCoordination) Command() string     { return POINTER_COORDINATION }
+func (PointerDismissed) Command() string        { return POINTER_DISMISSED }
+
+func (m Paired) Lines() []string                  { return []string{m.Command()} }
+func (m ValidatingPIN) Lines() []string           { return []string{m.Command()} }
+func (m ServerVersion) Lines() []string           { return []string{m.Command(), m.Version} }
+func (m SlideShowInfo) Lines() []string           { return []string{m.Command(), m.Title} }
+func (m SlideShowFinished) Lines() []string       { return []string{m.Command()} }
+func (m SlideUpdated) Lines() []string            { return []string{m.Command(), strconv.Itoa(m.Current)} }
+func (m Preview) Lines() []string                 { return []string{m.Command(), strconv.Itoa(m.Slide), m.Image} }
+func (m PairRequest) Lines() []string             { return []string{m.Command(), m.Name, m.PIN} }
+func (m TransitionNext) Lines() []string          { return []string{m.Command()} }
+func (m TransitionPrevious) Lines() []string      { return []string{m.Command()} }
+func (m GoToSlide) Lines() []string               { return []string{m.Command(), strconv.Itoa(m.Index)} }
+func (m PresentationBlankScreen) Lines() []string { return []string{m.Command()} }
+func (m PresentationResume) Lines() []string      { return []string{m.Command()} }
+func (m PresentationStart) Lines() []string       { return []string{m.Command()} }
+func (m PresentationStop) Lines() []string        { return []string{m.Command()} }
+func (m PointerDismissed) Lines() []string        { return []string{m.Command()} }
+
+func (m SlideShowStarted) Lines() []string {
+	return []string{m.Command(), strconv.Itoa(m.Total), strconv.Itoa(m.Current)}
+}
+
+func (m Notes) Lines() []string {
+	return append([]string{m.Command(), strconv.Itoa(m.Slide)}, strings.Split(m.Notes, "\n")...)
+}
+
+func (m PointerStarted) Lines() []string {
+	return []string{m.Command(), formatCoordinate(m.X), formatCoordinate(m.Y)}
+}
+
+func (m PointerCoordination) Lines() []string {
+	return []string{m.Command(), formatCoordinate(m.X), formatCoordinate(m.Y)}
+}
+
+// ParseMessage validates the lines of a decoded message and converts them to their typed message
+func ParseMessage(lines []string) (ProtocolMessage, error) {
+	if len(lines) == 0 {
+		return nil, ErrEmptyMessage
+	}
+
+	args := lines[1:]
+	switch lines[0] {
+	case PAIRED:
+		return Paired{}, nil
+	case VALIDATING:
+		return ValidatingPIN{}, nil
+	case SERVER_INFO:
+		if len(args) < 1 {
+			return nil, malformed(lines[0])
+		}
+		return ServerVersion{Version: args[0]}, nil
+	case SLIDE_SHOW_INFO:
+		if len(args) < 1 {
+			return nil, malformed(lines[0])
+		}
+		return SlideShowInfo{Title: strings.Join(args, "\n")}, nil
+	case SLIDE_SHOW_STARTED:
+		total, current, ok := parseTwoInts(args)
+		if !ok || current >= total {
+			return nil, malformed(lines[0])
+		}
+		return SlideShowStarted{Total: total, Current: current}, nil
+	case SLIDE_SHOW_FINISHED:
+		return SlideShowFinished{}, nil
+	case SLIDE_UPDATED:
+		current, ok := parseIndex(args)
+		if !ok || len(args) != 1 {
+			return nil, malformed(lines[0])
+		}
+		return SlideUpdated{Current: current}, nil
+	case SLIDE_PREVIEW:
+		slide, ok := parseIndex(args)
+		if !ok || len(args) < 2 {
+			return nil, malformed(lines[0])
+		}
+		// Long previews may be wrapped over several lines
+		return Preview{Slide: slide, Image: strings.Join(args[1:], "")}, nil
+	case SLIDE_NOTES:
+		slide, ok := parseIndex(args)
+		if !ok {
+			return nil, malformed(lines[0])
+		}
+		return Notes{Slide: slide, Notes: strings.Join(args[1:], "\n")}, nil
+	case PAIR_WITH_SERVER:
+		if len(args) != 2 {
+			return nil, malformed(lines[0])
+		}
+		return PairRequest{Name: args[0], PIN: args[1]}, nil
+	case TRANSITION_NEXT:
+		return TransitionNext{}, nil
+	case TRANSITION_PREVIOUS:
+		return TransitionPrevious{}, nil
+	case GO_TO_SLIDE:
+		index, ok := parseIndex(args)
+		if !ok || len(args) != 1 {
+			return nil, malformed(lines[0])
+		}
+		return GoToSlide{Index: index}, nil
+	case PRESENTATION_BLANK_SCREEN:
+		return PresentationBlankScreen{}, nil
+	case PRESENTATION_RESUME:
+		return PresentationResume{}, nil
+	case PRESENTATION_START:
+		return PresentationStart{}, nil
+	case PRESENTATION_STOP:
+		return PresentationStop{}, nil
+	case POINTER_STARTED, POINTER_COORDINATION:
+		if len(args) != 2 {
+			return nil, malformed(lines[0])
+		}
+		x, errX := parseCoordinate(args[0])
+		y, errY := parseCoordinate(args[1])
+		if errX != nil || errY != nil {
+			return nil, malformed(lines[0])
+		}
+		if lines[0] == POINTER_STARTED {
+			return PointerStarted{X: x, Y: y}, nil
+		}
+		return PointerCoordination{X: x, Y: y}, nil
+	case POINTER_DISMISSED:
+		return PointerDismissed{}, nil
+	default:
+		return nil, &ProtocolError{Reason: "unknown command " + lines[0]}
+	}
+}
+
+// Message returns the message describing the slideshow status, or nil if nothing is known about it yet
+func (status SlideShowStatus) Message() ProtocolMessage {
+	switch status.State {
+	case STATE_RUNNING:
+		if status.TotalSlides > 0 {
+			return SlideShowStarted{Total: status.TotalSlides, Current: status.CurrentSlide}
+		}
+		return SlideUpdated{Current: status.CurrentSlide}
+	case STATE_FINISHED:
+		return SlideShowFinished{}
+	default:
+		return nil
+	}
+}
+
+func (status SlideShowStatus) IsRunning() bool {
+	return status.State == STATE_RUNNING
+}
+
+func malformed(command string) error {
+	return &ProtocolError{Reason: "malformed " + command + " message"}
+}
+
+func parseIndex(args []string) (int, bool) {
+	if len(args) < 1 {
+		return 0, false
+	}
+	index, err := strconv.Atoi(args[0])
+	return index, err == nil && index >= 0
+}
+
+func parseTwoInts(args []string) (int, int, bool) {
+	if len(args) != 2 {
+		return 0, 0, false
+	}
+	first, ok1 := parseIndex(args[0:1])
+	second, ok2 := parseIndex(args[1:2])
+	return first, second, ok1 && ok2
+}
+
+func parseCoordinate(value string) (float64, error) {
+	conv, err := strconv.ParseFloat(value, 64)
+	if err != nil || conv < 0 || conv > 1 {
+		return 0, malformed("coordinate")
+	}
+	return conv, nil
+}
+
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,51 @@
+package impress
+
+import (
+	testing "testing"
+)
+
+func TestParseMessageRoundTrip(t *testing.T) {
+	messages := []ProtocolMessage{
+		Paired{},
+		SlideShowInfo{Title: "Quarterly review"},
+		SlideShowStarted{Total: 12, Current: 3},
+		SlideUpdated{Current: 4},
+		Preview{Slide: 4, Image: "cHJldmlldw=="},
+		Notes{Slide: 4, Notes: "<p>first</p>\n<p>second</p>"},
+		PairRequest{Name: "Remote", PIN: "1234"},
+		GoToSlide{Index: 7},
+		PointerCoordination{X: 0.25, Y: 1},
+		PresentationStop{},
+	}
+	for _, message := range messages {
+		parsed, err := ParseMessage(message.Lines())
+		if err != nil {
+			t.Errorf("ParseMessage(%v): %v", message.Lines(), err)
+			continue
+		}
+		if parsed != message {
+			t.Errorf("parsed %#v, expected %#v", parsed, message)
+		}
+	}
+}
+
+func TestParseMessageRejectsMalformed(t *testing.T) {
+	malformed := [][]string{
+		{SLIDE_SHOW_STARTED, "5"},
+		{SLIDE_SHOW_STARTED, "5", "5"},
+		{SLIDE_UPDATED},
+		{SLIDE_UPDATED, "-1"},
+		{SLIDE_PREVIEW, "one", "cHJldmlldw=="},
+		{SLIDE_PREVIEW, "1"},
+		{GO_TO_SLIDE, "next"},
+		{POINTER_STARTED, "0.5", "1.5"},
+		{"unknown_command"},
+	}
+	for _, lines := range malformed {
+		if _, err := ParseMessage(lines); err == nil {
+			t.Errorf("ParseMessage(%v) succeeded", lines)
+		} else if _, ok := err.(*ProtocolError); !ok {
+			t.Errorf("ParseMessage(%v) returned %T, expected a protocol error", lines, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package impress
+
+import (
+	net "net"
+	utf8 "unicode/utf8"
+
+	websocket "github.com/gorilla/websocket"
+)
+
+const (
+	LIST_CONTROLLERS = "list_controllers"
+	KICK             = "kick"
+	BAN              = "ban"
+
+	BAN_BY_PARTICIPANT = "participant"
+	BAN_BY_ADDRESS     = "address"
+
+	MAX_KICK_REASON_LENGTH = 120
+)
+
+type ListControllers struct{}
+
+type Kick struct {
+	ControllerID string
+	Reason       string
+}
+
+// Ban kicks a controller and keeps its participant ID, or its whole remote address, out for the rest of the session
+type Ban struct {
+	ControllerID string
+	Reason       string
+	ByAddress    bool
+}
+
+// disconnect makes the write pump close the websocket with a reason, it is never encoded.
+// The error, if any, is written right before closing
+type disconnect struct {
+	Code   int
+	Reason string
+	Error  *Error
+}
+
+func (ListControllers) Command() string { return LIST_CONTROLLERS }
+func (Kick) Command() string            { return KICK }
+func (Ban) Command() string             { return BAN }
+func (disconnect) Command() string      { return "disconnect" }
+
+// IsBanned tells whether a participant ID or a remote address was banned from the session
+func (impr *ImpressClient) IsBanned(participantID string, remoteAddr string) bool {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	return (participantID != "" && impr.bannedParticipants[participantID]) || impr.bannedAddresses[remoteHost(remoteAddr)]
+}
+
+func (impr *ImpressClient) listControllers(to *ImpressController) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if !impr.isTerminated && impr.isRegisteredLocked(to) {
+		to.send <- Roster{Participants: impr.participantsLocked()}
+	}
+}
+
+func (impr *ImpressClient) kick(controllerID string, reason string) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	controller, err := impr.moderatedControllerLocked(controllerID)
+	if err != nil {
+		return err
+	}
+	Logger.InfoF("Controller %s was kicked", controllerID)
+	controller.send <- disconnect{Code: websocket.ClosePolicyViolation, Reason: kickReason(reason, "Kicked by the owner")}
+	return nil
+}
+
+// ban disconnects every controller the ban applies to, which can be several when banning an address
+func (impr *ImpressClient) ban(controllerID string, reason string, byAddress bool) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	controller, err := impr.moderatedControllerLocked(controllerID)
+	if err != nil {
+		return err
+	}
+	impr.bannedParticipants[controllerID] = true
+	if host := remoteHost(controller.participant.RemoteAddr); byAddress && host != "" {
+		impr.bannedAddresses[host] = true
+	}
+	Logger.InfoF("Controller %s was banned", controllerID)
+
+	reason = kickReason(reason, "Banned by the owner")
+	for _, connected := range impr.controllers {
+		if connected.IsOwner() {
+			continue
+		}
+		if impr.bannedParticipants[connected.participant.ID] || impr.bannedAddresses[remoteHost(connected.participant.RemoteAddr)] {
+			connected.send <- disconnect{Code: websocket.ClosePolicyViolation, Reason: reason}
+		}
+	}
+	impr.stateChangedLocked()
+	return nil
+}
+
+func (impr *ImpressClient) moderatedControllerLocked(controllerID string) (*ImpressController, error) {
+	if impr.isTerminated {
+		return nil, ErrNotRunning
+	}
+	for _, controller := range impr.controllers {
+		if controller.participant.ID == controllerID {
+			if controller.IsOwner() {
+				return nil, NewError(ERR_CONFLICT, "The owner can't be kicked")
+			}
+			return controller, nil
+		}
+	}
+	return nil, NewError(ERR_NOT_FOUND, "Controller not found")
+}
+
+// kickReason has to fit in a close frame, whose payload is limited to 125 bytes
+func kickReason(reason string, fallback string) string {
+	if reason == "" {
+		return fallback
+	}
+	for len(reason) > MAX_KICK_REASON_LENGTH {
+		_, size := utf8.DecodeLastRuneInString(reason)
+		reason = reason[:len(reason)-size]
+	}
+	return reason
+}
+
+// remoteHost strips the port, so that a banned address can't come back from another one
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
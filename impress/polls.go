@@ -0,0 +1,193 @@
+package impress
+
+import (
+	fmt "fmt"
+	strings "strings"
+	time "time"
+	utf8 "unicode/utf8"
+)
+
+const (
+	OPEN_POLL  = "open_poll"
+	CLOSE_POLL = "close_poll"
+	VOTE       = "vote"
+	POLL       = "poll"
+	REACT      = "react"
+	REACTIONS  = "reactions"
+
+	MIN_POLL_OPTIONS       = 2
+	MAX_POLL_OPTIONS       = 10
+	MAX_POLL_OPTION_LENGTH = 100
+
+	// Reactions are counted and sent to the controllers once per period, however many arrive
+	REACTIONS_PERIOD = time.Second
+)
+
+// Emojis the audience can react with
+var AllowedReactions = []string{"👍", "👏", "❤️", "😂", "😮", "🎉"}
+
+// Poll opened by the owner. Every participant has one vote, counted in the tallies
+type Poll struct {
+	ID       string
+	Question string
+	Options  []string
+	Tallies  []int
+	IsOpen   bool
+	votes    map[string]int
+}
+
+type OpenPoll struct {
+	Question string
+	Options  []string
+}
+type ClosePoll struct{}
+type Vote struct {
+	PollID string
+	Option int
+}
+type React struct{ Emoji string }
+
+// Reactions holds how many of each emoji arrived during the last period
+type Reactions struct {
+	Counts map[string]int
+}
+
+func (OpenPoll) Command() string  { return OPEN_POLL }
+func (ClosePoll) Command() string { return CLOSE_POLL }
+func (Vote) Command() string      { return VOTE }
+func (Poll) Command() string      { return POLL }
+func (React) Command() string     { return REACT }
+func (Reactions) Command() string { return REACTIONS }
+
+func (impr *ImpressClient) openPoll(question string, options []string) error {
+	question = strings.TrimSpace(question)
+	if question == "" || utf8.RuneCountInString(question) > MAX_QUESTION_LENGTH {
+		return fieldError(ERR_INVALID_ARGUMENT, "question", fmt.Sprintf("Poll question must have between 1 and %d characters", MAX_QUESTION_LENGTH))
+	}
+	if len(options) < MIN_POLL_OPTIONS || len(options) > MAX_POLL_OPTIONS {
+		return fieldError(ERR_INVALID_ARGUMENT, "options", fmt.Sprintf("Poll must have between %d and %d options", MIN_POLL_OPTIONS, MAX_POLL_OPTIONS))
+	}
+	trimmed := make([]string, 0, len(options))
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > MAX_POLL_OPTION_LENGTH {
+			return fieldError(ERR_INVALID_ARGUMENT, "options", fmt.Sprintf("Poll options must have between 1 and %d characters", MAX_POLL_OPTION_LENGTH))
+		}
+		trimmed = append(trimmed, option)
+	}
+
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	if impr.poll != nil && impr.poll.IsOpen {
+		return NewError(ERR_CONFLICT, "A poll is already open")
+	}
+	impr.poll = &Poll{
+		ID:       newToken(8),
+		Question: question,
+		Options:  trimmed,
+		Tallies:  make([]int, len(trimmed)),
+		IsOpen:   true,
+		votes:    make(map[string]int),
+	}
+	Logger.InfoF("Opened poll %s", impr.poll.ID)
+	impr.broadcastPollLocked()
+	return nil
+}
+
+func (impr *ImpressClient) closePoll() error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	if impr.poll == nil || !impr.poll.IsOpen {
+		return NewError(ERR_CONFLICT, "No poll is open")
+	}
+	impr.poll.IsOpen = false
+	Logger.InfoF("Closed poll %s", impr.poll.ID)
+	impr.broadcastPollLocked()
+	return nil
+}
+
+func (impr *ImpressClient) vote(from *ImpressController, pollID string, option int) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	poll := impr.poll
+	if poll == nil || poll.ID != pollID || !poll.IsOpen {
+		return NewError(ERR_CONFLICT, "Poll is not open")
+	}
+	if option < 0 || option >= len(poll.Options) {
+		return fieldError(ERR_INVALID_ARGUMENT, "option", "Poll option not found")
+	}
+	// Counted per participant, which survives reconnecting only with the resume token. A client dropping its token
+	// joins as somebody new and votes again, which is as far as anonymous audiences can be held to one vote
+	voter := from.participant.ID
+	if _, ok := poll.votes[voter]; ok {
+		return NewError(ERR_CONFLICT, "Already voted in this poll")
+	}
+	poll.votes[voter] = option
+	poll.Tallies[option]++
+	impr.broadcastPollLocked()
+	return nil
+}
+
+func (impr *ImpressClient) react(emoji string) error {
+	allowed := false
+	for _, reaction := range AllowedReactions {
+		if reaction == emoji {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fieldError(ERR_INVALID_ARGUMENT, "emoji", "Reaction not allowed")
+	}
+
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	impr.reactions[emoji]++
+	return nil
+}
+
+// flushReactions sends the reactions of the last period to every controller
+func (impr *ImpressClient) flushReactions() {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated || len(impr.reactions) == 0 {
+		return
+	}
+	message := Reactions{Counts: impr.reactions}
+	impr.reactions = make(map[string]int)
+	for _, controller := range impr.controllers {
+		controller.send <- message
+	}
+}
+
+func (impr *ImpressClient) broadcastPollLocked() {
+	poll := impr.poll.copy()
+	for _, controller := range impr.controllers {
+		controller.send <- poll
+	}
+}
+
+// copy leaves out who voted what, which stays private
+func (poll *Poll) copy() Poll {
+	copied := *poll
+	copied.Options = append([]string{}, poll.Options...)
+	copied.Tallies = append([]int{}, poll.Tallies...)
+	copied.votes = nil
+	return copied
+}
@@ -0,0 +1,184 @@
+package impress
+
+import (
+	fmt "fmt"
+	math "math"
+	sort "sort"
+	strings "strings"
+	time "time"
+	utf8 "unicode/utf8"
+)
+
+const (
+	ASK_QUESTION     = "ask_question"
+	UPVOTE_QUESTION  = "upvote_question"
+	ANSWER_QUESTION  = "answer_question"
+	DISMISS_QUESTION = "dismiss_question"
+	QUESTIONS        = "questions"
+
+	MAX_QUESTION_LENGTH = 280
+)
+
+// Minimum time between two questions of the same participant. Replaceable for tests
+var QuestionInterval = 10 * time.Second
+
+type QuestionState string
+
+const (
+	QUESTION_OPEN      QuestionState = "open"
+	QUESTION_ANSWERED  QuestionState = "answered"
+	QUESTION_DISMISSED QuestionState = "dismissed"
+)
+
+// Question asked by the audience, tied to the slide that was shown when it was asked
+type Question struct {
+	ID         string
+	Text       string
+	Slide      int
+	AuthorID   string
+	AuthorName string
+	AskedAt    time.Time
+	Upvoters   []string
+	State      QuestionState
+}
+
+type AskQuestion struct{ Text string }
+type UpvoteQuestion struct{ QuestionID string }
+type AnswerQuestion struct{ QuestionID string }
+type DismissQuestion struct{ QuestionID string }
+
+// Questions is the queue of open questions, the most upvoted first
+type Questions struct {
+	Questions []Question
+}
+
+func (AskQuestion) Command() string     { return ASK_QUESTION }
+func (UpvoteQuestion) Command() string  { return UPVOTE_QUESTION }
+func (AnswerQuestion) Command() string  { return ANSWER_QUESTION }
+func (DismissQuestion) Command() string { return DISMISS_QUESTION }
+func (Questions) Command() string       { return QUESTIONS }
+
+// GetQuestions returns every question of the session in the order they were asked, whatever their state
+func (impr *ImpressClient) GetQuestions() []Question {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	questions := make([]Question, 0, len(impr.questions))
+	for _, question := range impr.questions {
+		questions = append(questions, question.copy())
+	}
+	return questions
+}
+
+func (impr *ImpressClient) askQuestion(from *ImpressController, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fieldError(ERR_INVALID_ARGUMENT, "text", "Question can't be empty")
+	}
+	if utf8.RuneCountInString(text) > MAX_QUESTION_LENGTH {
+		return fieldError(ERR_INVALID_ARGUMENT, "text", fmt.Sprintf("Question can't be longer than %d characters", MAX_QUESTION_LENGTH))
+	}
+
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated {
+		return ErrNotRunning
+	}
+	// The limit follows the participant ID, so it only holds across reconnects made with the resume token
+	author := from.participant
+	if last, ok := impr.lastQuestionAt[author.ID]; ok && time.Since(last) < QuestionInterval {
+		retryAfter := int(math.Ceil((QuestionInterval - time.Since(last)).Seconds()))
+		return NewError(ERR_RATE_LIMITED, fmt.Sprintf("Only one question can be asked every %d seconds", int(QuestionInterval.Seconds()))).
+			WithDetail("retryAfter", retryAfter)
+	}
+	impr.lastQuestionAt[author.ID] = time.Now()
+
+	impr.questions = append(impr.questions, &Question{
+		ID:         newToken(8),
+		Text:       text,
+		Slide:      impr.stats.Status.CurrentSlide,
+		AuthorID:   author.ID,
+		AuthorName: author.Name,
+		AskedAt:    time.Now(),
+		Upvoters:   make([]string, 0),
+		State:      QUESTION_OPEN,
+	})
+	impr.questionsChangedLocked()
+	return nil
+}
+
+func (impr *ImpressClient) upvoteQuestion(from *ImpressController, questionID string) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	question, err := impr.openQuestionLocked(questionID)
+	if err != nil {
+		return err
+	}
+	voter := from.participant.ID
+	if question.AuthorID == voter {
+		return NewError(ERR_CONFLICT, "Own questions can't be upvoted")
+	}
+	for _, upvoter := range question.Upvoters {
+		if upvoter == voter {
+			return NewError(ERR_CONFLICT, "Question was already upvoted")
+		}
+	}
+	question.Upvoters = append(question.Upvoters, voter)
+	impr.questionsChangedLocked()
+	return nil
+}
+
+// closeQuestion marks an open question as answered or dismissed, which takes it out of the queue
+func (impr *ImpressClient) closeQuestion(questionID string, state QuestionState) error {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	question, err := impr.openQuestionLocked(questionID)
+	if err != nil {
+		return err
+	}
+	question.State = state
+	impr.questionsChangedLocked()
+	return nil
+}
+
+func (impr *ImpressClient) openQuestionLocked(questionID string) (*Question, error) {
+	if impr.isTerminated {
+		return nil, ErrNotRunning
+	}
+	for _, question := range impr.questions {
+		if question.ID == questionID && question.State == QUESTION_OPEN {
+			return question, nil
+		}
+	}
+	return nil, NewError(ERR_NOT_FOUND, "Question not found")
+}
+
+func (impr *ImpressClient) questionsChangedLocked() {
+	message := impr.openQuestionsLocked()
+	for _, controller := range impr.controllers {
+		controller.send <- message
+	}
+	impr.stateChangedLocked()
+}
+
+func (impr *ImpressClient) openQuestionsLocked() Questions {
+	open := make([]Question, 0)
+	for _, question := range impr.questions {
+		if question.State == QUESTION_OPEN {
+			open = append(open, question.copy())
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		return len(open[i].Upvoters) > len(open[j].Upvoters)
+	})
+	return Questions{Questions: open}
+}
+
+func (question *Question) copy() Question {
+	copied := *question
+	copied.Upvoters = append([]string{}, question.Upvoters...)
+	return copied
+}
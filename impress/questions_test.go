@@ -0,0 +1,21 @@
+package impress
+
+import (
+	testing "testing"
+	time "time"
+)
+
+func TestOpenQuestionsAreSortedByUpvotes(t *testing.T) {
+	asked := time.Now()
+	client := &ImpressClient{questions: []*Question{
+		{ID: "first", AskedAt: asked, Upvoters: []string{"a"}, State: QUESTION_OPEN},
+		{ID: "answered", AskedAt: asked, Upvoters: []string{"a", "b", "c"}, State: QUESTION_ANSWERED},
+		{ID: "popular", AskedAt: asked.Add(time.Second), Upvoters: []string{"a", "b"}, State: QUESTION_OPEN},
+		{ID: "tied", AskedAt: asked.Add(2 * time.Second), Upvoters: []string{"b"}, State: QUESTION_OPEN},
+	}}
+
+	open := client.openQuestionsLocked().Questions
+	if len(open) != 3 || open[0].ID != "popular" || open[1].ID != "first" || open[2].ID != "tied" {
+		t.Errorf("unexpected order %v", open)
+	}
+}
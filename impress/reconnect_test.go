@@ -0,0 +1,44 @@
+package impress
+
+import (
+	io "io"
+	net "net"
+	testing "testing"
+	time "time"
+)
+
+func TestReconnectClosesBrokenConnection(t *testing.T) {
+	client := NewClient("tcp://localhost:1599", "")
+	client.configs.maxReconnects = 0
+	broken, remote := net.Pipe()
+	defer remote.Close()
+	client.conn = broken
+
+	if decoder := client.reconnect(); decoder != nil {
+		t.Fatal("reconnected without any attempt")
+	}
+	broken.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := broken.Write([]byte("ping")); err != io.ErrClosedPipe {
+		t.Error("broken connection was left open")
+	}
+}
+
+func TestEndedSessionStaysTerminated(t *testing.T) {
+	client := NewClient("tcp://localhost:1599", "")
+	client.configs.maxReconnects = 0
+	conn, remote := net.Pipe()
+	defer remote.Close()
+	client.conn = conn
+	client.decoder = NewDecoder(conn, DEFAULT_MAX_MESSAGE_SIZE)
+	// Stopped over HTTP while the remote was being authorised
+	client.End(END_STOPPED_BY_OWNER)
+
+	client.ListenAndServe()
+	if session := client.GetStats().Session; session != SESSION_TERMINATED {
+		t.Errorf("session is %s after it was started once terminated", session)
+	}
+	client.reconnect()
+	if session := client.GetStats().Session; session != SESSION_TERMINATED {
+		t.Errorf("session is %s after it was reconnected once terminated", session)
+	}
+}
@@ -0,0 +1,103 @@
+package impress
+
+import (
+	http "net/http"
+	httptest "net/http/httptest"
+	strings "strings"
+	testing "testing"
+	time "time"
+
+	websocket "github.com/gorilla/websocket"
+)
+
+func TestTurnedAwayControllerIsIgnored(t *testing.T) {
+	client := NewClient("tcp://localhost:1599", "")
+	owner := &ImpressController{role: ROLE_OWNER, send: make(chan Message, 8)}
+	client.controllers = []*ImpressController{owner}
+	client.stats.Controllers = client.configs.maxControllers
+
+	// Concurrent joins can all pass the check for space, the one registered last is turned away
+	socket, conn := websocketPair(t)
+	ghost := NewController(socket, ROLE_VIEWER, Participant{}, "")
+	ghost.StartPumping(client)
+	conn.WriteJSON(map[string]string{"command": REQUEST_CONTROL})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+				t.Fatalf("turned away controller was not told the room is full: %v", err)
+			}
+			break
+		}
+	}
+
+	// Its channel is closed, so a request handled after all would crash the server once approved
+	if err := client.requestControl(ghost); err == nil {
+		t.Error("controller that never joined requested control")
+	}
+	if err := client.approveControl(ghost.ID(), time.Minute); err == nil || AsError(err).Code != ERR_NOT_FOUND {
+		t.Errorf("control request of a controller that never joined was approved: %v", err)
+	}
+	if err := client.createInvite(ghost, ROLE_VIEWER); err == nil {
+		t.Error("controller that never joined created an invite")
+	}
+	client.listControllers(ghost)
+}
+
+func TestSlowControllerIsDropped(t *testing.T) {
+	client := NewClient("tcp://localhost:1599", "")
+	socket, conn := websocketPair(t)
+	// Nothing pumps the messages of this controller, like a client that stopped reading
+	slow := NewController(socket, ROLE_VIEWER, Participant{}, "")
+	client.controllers = []*ImpressController{slow}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i <= sendBufferSize; i++ {
+			client.broadcast(SlideStatus{Status: SlideUpdated{Current: i}})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast waited for a controller that stopped reading")
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("controller that fell behind was not dropped")
+	}
+}
+
+func TestControllerOfEndedSessionIsClosed(t *testing.T) {
+	client := NewClient("tcp://localhost:1599", "")
+	client.End(END_STOPPED_BY_OWNER)
+
+	socket, conn := websocketPair(t)
+	NewController(socket, ROLE_VIEWER, Participant{}, "").StartPumping(client)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("controller joined a session that ended")
+	} else if _, ok := err.(*websocket.CloseError); !ok {
+		t.Errorf("controller of an ended session was left waiting: %v", err)
+	}
+}
+
+// websocketPair returns both ends of a websocket, the one accepted by the server first
+func websocketPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	accepted := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := upgrader.Upgrade(w, r, nil); err == nil {
+			accepted <- conn
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return <-accepted, conn
+}
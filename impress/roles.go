@@ -0,0 +1,63 @@
+package impress
+
+type Role string
+
+const (
+	ROLE_OWNER        Role = "owner"
+	ROLE_CO_PRESENTER Role = "co_presenter"
+	ROLE_VIEWER       Role = "viewer"
+)
+
+var Roles = []Role{ROLE_OWNER, ROLE_CO_PRESENTER, ROLE_VIEWER}
+
+// Commands co-presenters may use besides the owner. Everything else is reserved to the owner, while viewers are read-only
+var coPresenterCommands = map[string]bool{
+	TRANSITION_NEXT:      true,
+	TRANSITION_PREVIOUS:  true,
+	GO_TO_SLIDE:          true,
+	POINTER_STARTED:      true,
+	POINTER_COORDINATION: true,
+	POINTER_DISMISSED:    true,
+	ASK_QUESTION:         true,
+	UPVOTE_QUESTION:      true,
+	VOTE:                 true,
+	REACT:                true,
+}
+
+// Commands viewers may use, which never change the presentation itself
+var viewerCommands = map[string]bool{
+	REQUEST_CONTROL: true,
+	ASK_QUESTION:    true,
+	UPVOTE_QUESTION: true,
+	VOTE:            true,
+	REACT:           true,
+}
+
+// ParseRole accepts the roles that can be granted through an invite, since there is only one owner
+func ParseRole(value string) (Role, bool) {
+	switch role := Role(value); role {
+	case ROLE_CO_PRESENTER, ROLE_VIEWER:
+		return role, true
+	default:
+		return "", false
+	}
+}
+
+func (role Role) Allows(request Message) bool {
+	switch role {
+	case ROLE_OWNER:
+		return true
+	case ROLE_CO_PRESENTER:
+		return coPresenterCommands[request.Command()]
+	default:
+		return viewerCommands[request.Command()]
+	}
+}
+
+// permissionError describes why a role was refused a command
+func (role Role) permissionError() *Error {
+	if role == ROLE_VIEWER {
+		return NewError(ERR_PERMISSION_DENIED, "Viewers can't control the presentation")
+	}
+	return NewError(ERR_NOT_OWNER, "Only the owner can control the presentation")
+}
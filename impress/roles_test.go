@@ -0,0 +1,37 @@
+package impress
+
+import (
+	testing "testing"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role    Role
+		request Message
+		allowed bool
+	}{
+		{ROLE_OWNER, PresentationStop{}, true},
+		{ROLE_OWNER, TransferOwnership{ControllerID: "id"}, true},
+		{ROLE_CO_PRESENTER, TransitionNext{}, true},
+		{ROLE_CO_PRESENTER, GoToSlide{Index: 2}, true},
+		{ROLE_CO_PRESENTER, PointerCoordination{X: 0.5, Y: 0.5}, true},
+		{ROLE_CO_PRESENTER, PresentationBlankScreen{}, false},
+		{ROLE_CO_PRESENTER, CreateInvite{Role: ROLE_VIEWER}, false},
+		{ROLE_VIEWER, TransitionNext{}, false},
+		{ROLE_VIEWER, PointerStarted{X: 0, Y: 0}, false},
+	}
+	for _, test := range tests {
+		if allowed := test.role.Allows(test.request); allowed != test.allowed {
+			t.Errorf("%s allowed %s: %v, expected %v", test.role, test.request.Command(), allowed, test.allowed)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	if role, ok := ParseRole("co_presenter"); !ok || role != ROLE_CO_PRESENTER {
+		t.Errorf("co_presenter parsed as %q, %v", role, ok)
+	}
+	if _, ok := ParseRole("owner"); ok {
+		t.Error("owner role can be granted through an invite")
+	}
+}
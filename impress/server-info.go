@@ -0,0 +1,56 @@
+package impress
+
+import (
+	strconv "strconv"
+	strings "strings"
+)
+
+const CAPABILITY_POINTER = "pointer"
+
+// Oldest LibreOffice release understanding each capability. Releases that don't announce their version are older than all of them
+var capabilityVersions = map[string][]int{
+	CAPABILITY_POINTER: {5, 0},
+}
+
+// ServerInfo describes the LibreOffice instance behind the remote connection
+type ServerInfo struct {
+	Version      string
+	Capabilities []string
+}
+
+func NewServerInfo(version string) ServerInfo {
+	capabilities := make([]string, 0)
+	for capability, minVersion := range capabilityVersions {
+		if isVersionAtLeast(version, minVersion) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return ServerInfo{Version: version, Capabilities: capabilities}
+}
+
+func (info ServerInfo) Supports(capability string) bool {
+	for _, c := range info.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// isVersionAtLeast compares the leading numbers of a dotted version, like 7.3.4.2
+func isVersionAtLeast(version string, minVersion []int) bool {
+	parts := strings.Split(version, ".")
+	for i, min := range minVersion {
+		if i >= len(parts) {
+			return false
+		}
+		number, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return false
+		}
+		if number != min {
+			return number > min
+		}
+	}
+	return true
+}
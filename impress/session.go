@@ -0,0 +1,194 @@
+package impress
+
+import (
+	bytes "bytes"
+	errors "errors"
+	fmt "fmt"
+	ioutil "io/ioutil"
+	os "os"
+	filepath "path/filepath"
+	runtime "runtime"
+	strconv "strconv"
+	strings "strings"
+	syscall "syscall"
+	time "time"
+)
+
+// SessionSnapshot holds what is needed to re-attach to a running presentation after the backend restarted
+type SessionSnapshot struct {
+	OwnerUUID string
+	FilePath  string
+	PID       int
+	// Boot and start time of the process, since its PID may belong to another process after a reboot
+	ProcessIdentity string
+	Status          SlideShowStatus
+	Invites         map[string]Role
+	// Participant ID of every resume token, so that participants stay the authors of their questions after a restart
+	ResumeTokens map[string]string
+	Questions    []Question
+	Bans         Bans
+	StartedAt    time.Time
+}
+
+// Bans lists the participant IDs and remote addresses the owner banned from the session
+type Bans struct {
+	Participants []string
+	Addresses    []string
+}
+
+// Snapshot returns the current state of the presentation, or false once there is nothing left to resume
+func (impr *ImpressClient) Snapshot() (SessionSnapshot, bool) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.isTerminated || impr.presentation == nil {
+		return SessionSnapshot{}, false
+	}
+	invites := make(map[string]Role)
+	for token, role := range impr.invites {
+		invites[token] = role
+	}
+	resumeTokens := make(map[string]string)
+	for token, id := range impr.resumeTokens {
+		resumeTokens[token] = id
+	}
+	questions := make([]Question, 0, len(impr.questions))
+	for _, question := range impr.questions {
+		questions = append(questions, question.copy())
+	}
+	bans := Bans{Participants: make([]string, 0, len(impr.bannedParticipants)), Addresses: make([]string, 0, len(impr.bannedAddresses))}
+	for id := range impr.bannedParticipants {
+		bans.Participants = append(bans.Participants, id)
+	}
+	for address := range impr.bannedAddresses {
+		bans.Addresses = append(bans.Addresses, address)
+	}
+	snapshot := SessionSnapshot{
+		OwnerUUID:    impr.presentation.uuid,
+		FilePath:     impr.presentation.filePath,
+		Status:       impr.stats.Status,
+		Invites:      invites,
+		ResumeTokens: resumeTokens,
+		Questions:    questions,
+		Bans:         bans,
+		StartedAt:    impr.presentation.startedAt,
+	}
+	if impr.presentation.process != nil {
+		snapshot.PID = impr.presentation.process.Pid
+		snapshot.ProcessIdentity = impr.presentation.processIdentity
+	}
+	return snapshot, true
+}
+
+// SetStateListener registers a function called in the background whenever the snapshot of the client changes
+func (impr *ImpressClient) SetStateListener(listener func()) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	impr.listener = listener
+}
+
+func (impr *ImpressClient) stateChangedLocked() {
+	if impr.listener != nil {
+		go impr.listener()
+	}
+}
+
+// RestoreClient creates a client for the LibreOffice process of a snapshot, which has to be still running.
+// OpenConnection then re-attaches to it like to a freshly started presentation, and the last slide is restored
+func RestoreClient(remoteURL string, profileDirectory string, snapshot SessionSnapshot) (*ImpressClient, error) {
+	if _, err := os.Stat(snapshot.FilePath); err != nil {
+		return nil, err
+	}
+	process, err := findProcess(snapshot.PID, snapshot.ProcessIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewClient(remoteURL, profileDirectory)
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.presentation = &presentation{
+		uuid:            snapshot.OwnerUUID,
+		filePath:        snapshot.FilePath,
+		process:         process,
+		processIdentity: snapshot.ProcessIdentity,
+		startedAt:       snapshot.StartedAt,
+	}
+	client.stats.Status = snapshot.Status
+	for token, role := range snapshot.Invites {
+		client.invites[token] = role
+	}
+	for token, id := range snapshot.ResumeTokens {
+		client.resumeTokens[token] = id
+	}
+	for i := range snapshot.Questions {
+		question := snapshot.Questions[i].copy()
+		client.questions = append(client.questions, &question)
+	}
+	for _, id := range snapshot.Bans.Participants {
+		client.bannedParticipants[id] = true
+	}
+	for _, address := range snapshot.Bans.Addresses {
+		client.bannedAddresses[address] = true
+	}
+	client.restored = true
+	return client, nil
+}
+
+// DiscardSnapshot stops whatever is left of a presentation that can't be restored: its LibreOffice process,
+// if it still runs, and its uploaded file
+func DiscardSnapshot(snapshot SessionSnapshot) {
+	process, err := findProcess(snapshot.PID, snapshot.ProcessIdentity)
+	if err != nil {
+		process = nil
+	}
+	stopPresentation(process, snapshot.FilePath)
+}
+
+// findProcess returns the process with the given PID, if it is still running and is the one that was saved.
+// A process whose identity doesn't match is treated as gone, it must be neither attached to nor stopped
+func findProcess(pid int, identity string) (*os.Process, error) {
+	if pid <= 0 {
+		return nil, errors.New("Invalid process id")
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	// On Windows FindProcess already fails for processes that exited
+	if runtime.GOOS != "windows" {
+		if err := process.Signal(syscall.Signal(0)); err != nil {
+			return nil, err
+		}
+	}
+	// Without /proc there is nothing to compare, the PID is all there is
+	if current, err := ProcessIdentity(pid); err == nil && current != identity {
+		return nil, fmt.Errorf("Process %d is not the one of the presentation anymore", pid)
+	}
+	return process, nil
+}
+
+// ProcessIdentity reads the boot ID and the start time of a process from /proc. Together they identify it,
+// unlike its PID which is reused, also across reboots
+func ProcessIdentity(pid int) (string, error) {
+	bootID, err := ioutil.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	stat, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", err
+	}
+	// The command name comes second, between parentheses, and may contain spaces. The start time is the 22nd field
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return "", errors.New("Malformed process stat")
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return "", errors.New("Malformed process stat")
+	}
+	return strings.TrimSpace(string(bootID)) + ":" + fields[19], nil
+}
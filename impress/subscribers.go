@@ -0,0 +1,55 @@
+package impress
+
+// Events a subscriber can fall behind by, before it is dropped
+const SUBSCRIBER_BUFFER = 16
+
+// Subscribe registers a read-only listener of the slide status and of the session lifecycle, which receives
+// the current state first. Subscribers don't take up a controller slot. The channel is closed when the client
+// terminates, or when the subscriber falls too far behind
+func (impr *ImpressClient) Subscribe() (<-chan Message, func()) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	events := make(chan Message, SUBSCRIBER_BUFFER)
+	if impr.isTerminated {
+		close(events)
+		return events, func() {}
+	}
+	events <- SessionStatus{State: impr.stats.Session}
+	status := impr.stats.Status
+	if message := status.Message(); message != nil {
+		events <- SlideStatus{Status: message, Preview: impr.previews[status.CurrentSlide]}
+	}
+	impr.subscribers[events] = true
+	return events, func() { impr.unsubscribe(events) }
+}
+
+func (impr *ImpressClient) unsubscribe(events chan Message) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.subscribers[events] {
+		delete(impr.subscribers, events)
+		close(events)
+	}
+}
+
+// publishLocked never blocks on a subscriber. One that is full is dropped, it gets the current state again by resubscribing
+func (impr *ImpressClient) publishLocked(message Message) {
+	for events := range impr.subscribers {
+		select {
+		case events <- message:
+		default:
+			Logger.Warning("Dropped a subscriber that fell behind")
+			delete(impr.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+func (impr *ImpressClient) closeSubscribersLocked() {
+	for events := range impr.subscribers {
+		delete(impr.subscribers, events)
+		close(events)
+	}
+}
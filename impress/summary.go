@@ -0,0 +1,97 @@
+package impress
+
+import (
+	time "time"
+
+	websocket "github.com/gorilla/websocket"
+)
+
+const SESSION_ENDED = "session_ended"
+
+// EndReason tells the controllers why the presentation ended
+type EndReason string
+
+const (
+	END_TERMINATED          EndReason = "terminated"
+	END_STOPPED_BY_OWNER    EndReason = "stopped by owner"
+	END_OWNER_TIMEOUT       EndReason = "owner timeout"
+	END_IMPRESS_CRASHED     EndReason = "impress crashed"
+	END_IMPRESS_UNREACHABLE EndReason = "impress unreachable"
+	END_SERVER_SHUTDOWN     EndReason = "server shutdown"
+)
+
+// closeCode is the websocket close code controllers are disconnected with when the session ends
+func (reason EndReason) closeCode() int {
+	switch reason {
+	case END_SERVER_SHUTDOWN:
+		return websocket.CloseGoingAway
+	case END_IMPRESS_CRASHED, END_IMPRESS_UNREACHABLE:
+		return websocket.CloseInternalServerErr
+	default:
+		return websocket.CloseNormalClosure
+	}
+}
+
+// SessionEnded is the last message controllers receive before their connection is closed
+type SessionEnded struct {
+	Reason EndReason
+}
+
+func (SessionEnded) Command() string {
+	return SESSION_ENDED
+}
+
+// SessionSummary describes a presentation once it ended
+type SessionSummary struct {
+	Name              string
+	Reason            EndReason
+	StartedAt         time.Time
+	EndedAt           time.Time
+	TotalSlides       int
+	VisitedSlides     int
+	Controllers       int
+	Questions         int
+	AnsweredQuestions int
+}
+
+// End terminates the client for the given reason and returns the summary of the session.
+// If it already ended, the summary and reason of that first ending are returned
+func (impr *ImpressClient) End(reason EndReason) SessionSummary {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	impr.terminateLocked(reason)
+	return *impr.summary
+}
+
+// GetSummary returns the summary of the session, once it ended
+func (impr *ImpressClient) GetSummary() (SessionSummary, bool) {
+	impr.mu.Lock()
+	defer impr.mu.Unlock()
+
+	if impr.summary == nil {
+		return SessionSummary{}, false
+	}
+	return *impr.summary, true
+}
+
+func (impr *ImpressClient) summarizeLocked(reason EndReason) *SessionSummary {
+	summary := &SessionSummary{
+		Name:          impr.stats.Name,
+		Reason:        reason,
+		EndedAt:       time.Now(),
+		TotalSlides:   impr.stats.Status.TotalSlides,
+		VisitedSlides: len(impr.visited),
+		Controllers:   len(impr.controllers),
+		Questions:     len(impr.questions),
+	}
+	if impr.presentation != nil {
+		summary.StartedAt = impr.presentation.startedAt
+	}
+	for _, question := range impr.questions {
+		if question.State == QUESTION_ANSWERED {
+			summary.AnsweredQuestions++
+		}
+	}
+	return summary
+}
@@ -0,0 +1,21 @@
+package impress
+
+import (
+	testing "testing"
+)
+
+func TestSendingAfterEndIsIgnored(t *testing.T) {
+	client := NewClient("tcp://localhost:1599", "")
+	client.configs.sharePointer = true
+	owner := &ImpressController{role: ROLE_OWNER, send: make(chan Message, 8)}
+	viewer := &ImpressController{role: ROLE_VIEWER, send: make(chan Message, 8)}
+	client.controllers = []*ImpressController{owner, viewer}
+	client.notes[1] = "Speaker notes"
+	client.End(END_STOPPED_BY_OWNER)
+
+	// Impress events read before the end are handled afterwards, once the channels of the controllers are closed
+	client.broadcast(SlideStatus{Status: SlideUpdated{Current: 1}})
+	client.sendNotesToOwner(1)
+	client.sharePointer(viewer, PointerDismissed{})
+	client.listControllers(owner)
+}
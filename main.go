@@ -6,6 +6,7 @@ import (
 	os "os"
 	signal "os/signal"
 	filepath "path/filepath"
+	strings "strings"
 	time "time"
 
 	impress "github.com/DanInci/raspi-projector-backend/impress"
@@ -21,12 +22,17 @@ var (
 	logger              = setupLogger()
 	libreOfficePath     = conf.String("libre-office-path", "soffice", "Path for LibreOffice")
 	libreRemoteURL      = conf.String("libre-remote-url", "ws://localhost:1599", "The default URL for libre remote connection")
+	libreProfilesDir    = conf.String("libre-profiles-directory", "", "The directory holding a separate LibreOffice user profile for every room")
+	roomIDs             = conf.String("rooms", "default", "Comma separated IDs of the rooms, as id=url for the rooms that don't use libre-remote-url")
 	libreRemoteName     = conf.String("libre-remote-name", "WebServer", "The name for the remote")
 	libreRemotePIN      = conf.String("libre-remote-pin", "13579", "The PIN for the remote connection")
 	libreMaxControllers = conf.Int("libre-max-controllers", 10, "The maximum number of slideshow controllers allowed")
 	libreMaxTimeout     = conf.Int("libre-max-timeout", 60, "The number of seconds the slideshow owner is allowed to be disconnected before drop")
+	libreSharePointer   = conf.Bool("libre-share-pointer", false, "Whether the laser pointer is forwarded to the other controllers")
+	libreMaxReconnects  = conf.Int("libre-max-reconnects", 5, "The number of failed attempts to reconnect to impress before the slideshow is dropped")
 	maxUploadSize       = conf.Int("max-upload-size", 1024*1024*10, "The maximum upload size for files")
 	uploadsDirectory    = conf.String("uploads-directory", "uploads", "The directory where the uploaded files would be saved")
+	stateDirectory      = conf.String("state-directory", "state", "The directory where the presentation state is saved, to be resumed after a restart")
 	qrDirectory         = conf.String("qr-directory", "www-qr", "The directory from where the qr files are served")
 	networkSSID         = conf.String("network-ssid", "Dani's Raspberry", "The network SSID used to generate the connection QR Code")
 	networkPass         = conf.String("network-pass", "123456987asd", "The network password used to generate the connection QR Code")
@@ -55,8 +61,10 @@ func setupLogger() *log.Logger {
 	return log
 }
 
-func setupImpress() {
-	impress.Configure(*libreOfficePath, *libreRemoteURL, *libreRemoteName, *libreRemotePIN, *libreMaxControllers, *libreMaxTimeout)
+func setupImpress() error {
+	impress.Configure(*libreOfficePath, *libreRemoteName, *libreRemotePIN, *libreMaxControllers, *libreMaxTimeout, *libreSharePointer, *libreMaxReconnects)
+	server.StateDirectory = *stateDirectory
+	return server.SetupRooms(strings.Split(*roomIDs, ","), *libreRemoteURL, *libreProfilesDir)
 }
 
 func setupHTTPServer() *http.Server {
@@ -65,11 +73,10 @@ func setupHTTPServer() *http.Server {
 	r.Use(server.CorsMiddleware)
 	// r.Use(server.LoggingMiddleware)
 
-	r.HandleFunc("/stats", server.GetStats).Methods("GET")
+	server.RegisterRoutes(r.PathPrefix(server.API_PREFIX).Subrouter())
 
-	r.HandleFunc("/upload", server.UploadPPT).Methods("POST")
-
-	r.HandleFunc("/control", server.ServeImpressController).Methods("GET")
+	// The unversioned paths are kept for the clients that predate the versioned API
+	server.RegisterRoutes(r)
 
 	r.PathPrefix("/qr").Handler(http.StripPrefix("/qr", server.NewStaticServer(filepath.Join(filepath.Dir(os.Args[0]), *qrDirectory))))
 
@@ -121,9 +128,14 @@ func main() {
 		logger.Fatal("Shutting down...")
 	}
 
-	setupImpress()
+	if err := setupImpress(); err != nil {
+		logger.CriticalF("Failed to setup rooms: %v", err)
+		logger.Fatal("Shutting down...")
+	}
 
 	httpServer := setupHTTPServer()
+	server.RestoreRooms()
+
 	logger.InfoF("Starting http server on %s...", httpServer.Addr)
 	go func() {
 		err := httpServer.ListenAndServe()
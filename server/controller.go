@@ -2,12 +2,13 @@ package server
 
 import (
 	json "encoding/json"
+	errors "errors"
 	ioutil "io/ioutil"
 	http "net/http"
 	os "os"
 	filepath "path/filepath"
 	strings "strings"
-	sync "sync"
+	time "time"
 
 	impress "github.com/DanInci/raspi-projector-backend/impress"
 	log "github.com/apsdehal/go-logger"
@@ -17,14 +18,15 @@ import (
 const DEFAULT_MAX_UPLOAD_SIZE = 1024
 const DEFAULT_UPLOAD_DIRECTORY = "upload"
 const OWNER_UUID = "ownerUUID"
+const INVITE_TOKEN = "invite"
+const RESUME_TOKEN = "resume"
+const PARTICIPANT_NAME = "name"
+const MAX_PARTICIPANT_NAME_LENGTH = 64
 
 var Logger *log.Logger
 var MaxUploadSize int = DEFAULT_MAX_UPLOAD_SIZE
 var UploadDirectory string = DEFAULT_UPLOAD_DIRECTORY
 
-var impressClient *impress.ImpressClient
-var mu sync.Mutex = sync.Mutex{}
-
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024 * 1024,
@@ -34,16 +36,20 @@ var upgrader = websocket.Upgrader{
 func GetStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if !isSlideShowRunning() {
-		writeError(w, "Slideshow is not running", http.StatusNotFound)
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusNotFound)
 		return
 	}
 
-	stats := getImpressClient().GetStats()
-	response, err := encodeImpressStats(&stats)
+	stats := room.getImpressClient().GetStats()
+	response, err := encodeImpressStats(room.ID, &stats)
 	if err != nil {
 		Logger.ErrorF("Error encoding stats: %v", err)
-		writeError(w, "Failed to get encode stats", http.StatusInternalServerError)
+		writeError(w, impress.NewError(impress.ERR_INTERNAL, "Failed to get encode stats"), http.StatusInternalServerError)
 		return
 	}
 
@@ -52,105 +58,180 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 }
 
 func UploadPPT(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+
 	r.Body = http.MaxBytesReader(w, r.Body, int64(MaxUploadSize))
 	if err := r.ParseMultipartForm(int64(MaxUploadSize)); err != nil {
 		Logger.InfoF("Error parsing MultipartForm: %v", err)
-		writeError(w, "File is too big", http.StatusBadRequest)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, impress.NewError(impress.ERR_UPLOAD_TOO_LARGE, "File is too big").WithDetail("maxSize", MaxUploadSize), http.StatusBadRequest)
+		} else {
+			writeError(w, impress.NewError(impress.ERR_INVALID_UPLOAD, "Invalid upload form"), http.StatusBadRequest)
+		}
 		return
 	}
 
 	fileName := r.PostFormValue("fileName")
 	if fileName == "" {
 		Logger.InfoF("Error fileName not found")
-		writeError(w, "'fileName' field not found", http.StatusBadRequest)
+		writeError(w, impress.NewError(impress.ERR_INVALID_UPLOAD, "'fileName' field not found").WithDetail("field", "fileName"), http.StatusBadRequest)
+		return
+	}
+	// The upload folder is removed with the presentation, a name escaping it would take other files along
+	if strings.ContainsAny(fileName, `/\`) || strings.Contains(fileName, "..") {
+		Logger.InfoF("Rejected upload named %q", fileName)
+		writeError(w, impress.NewError(impress.ERR_INVALID_UPLOAD, "'fileName' must be a file name without a path").WithDetail("field", "fileName"), http.StatusBadRequest)
 		return
 	}
 	file, _, err := r.FormFile("uploadFile")
 	if err != nil {
 		Logger.InfoF("Error uploadFile not found")
-		writeError(w, "'uploadFile' field not found", http.StatusBadRequest)
+		writeError(w, impress.NewError(impress.ERR_INVALID_UPLOAD, "'uploadFile' field not found").WithDetail("field", "uploadFile"), http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 	fileBytes, err := ioutil.ReadAll(file)
 	if err != nil {
 		Logger.InfoF("Error reading uploaded file: %v", err)
-		writeError(w, "Invalid file", http.StatusBadRequest)
+		writeError(w, impress.NewError(impress.ERR_INVALID_UPLOAD, "Invalid file"), http.StatusBadRequest)
 		return
 	}
+	// Legacy decks are compound documents, while .pptx decks are zip archives
 	fileType := http.DetectContentType(fileBytes)
-	if fileType != "application/octet-stream" || !(strings.HasSuffix(fileName, ".ppt") || strings.HasSuffix(fileName, ".pptx")) {
+	isPPT := fileType == "application/octet-stream" && strings.HasSuffix(fileName, ".ppt")
+	isPPTX := fileType == "application/zip" && strings.HasSuffix(fileName, ".pptx")
+	if !isPPT && !isPPTX {
 		Logger.InfoF("Invalid uploaded file type")
-		writeError(w, "Invalid file type", http.StatusBadRequest)
+		writeError(w, impress.NewError(impress.ERR_INVALID_FILE_TYPE, "Invalid file type"), http.StatusBadRequest)
 		return
 	}
 
-	if isSlideShowRunning() {
-		writeError(w, "Slideshow already running", http.StatusBadRequest)
-		return
-	}
-
-	uploadFolderPath := filepath.Join(filepath.Dir(os.Args[0]), UploadDirectory)
+	// The folder is removed together with the presentation, so every upload needs its own
+	uuid := generateUUID()
+	uploadFolderPath := filepath.Join(filepath.Dir(os.Args[0]), UploadDirectory, room.ID, uuid)
 	os.MkdirAll(uploadFolderPath, os.ModePerm)
-	filePath := filepath.Join(uploadFolderPath, fileName)
+	filePath := filepath.Join(uploadFolderPath, filepath.Base(fileName))
 
 	newFile, err := os.Create(filePath)
 	if err != nil {
 		Logger.ErrorF("Failed to upload file: %v", err)
-		writeError(w, "Failed to write file", http.StatusInternalServerError)
+		writeError(w, impress.NewError(impress.ERR_INTERNAL, "Failed to write file"), http.StatusInternalServerError)
 		return
 	}
 	defer newFile.Close()
 	if _, err := newFile.Write(fileBytes); err != nil {
 		Logger.ErrorF("Failed to upload file: %v", err)
-		writeError(w, "Failed to write file", http.StatusInternalServerError)
+		writeError(w, impress.NewError(impress.ERR_INTERNAL, "Failed to write file"), http.StatusInternalServerError)
 		return
 	}
 	Logger.InfoF("Uploaded file: %s\n", filePath)
 
-	uuid := generateUUID()
-	client := impress.NewClient()
-	if err := client.StartPresentation(uuid, filePath); err != nil {
+	queued := &queuedPresentation{ownerUUID: uuid, fileName: fileName, filePath: filePath, queuedAt: time.Now()}
+	position, err := room.startOrEnqueue(queued)
+	if err != nil {
 		Logger.ErrorF("Failed to start impress presentation: %v", err)
-		client.Terminate()
-		writeError(w, "Slideshow failed to start", http.StatusInternalServerError)
-		return
-	}
-	if err := client.OpenConnection(); err != nil {
-		Logger.ErrorF("Failed to open impress remote connection: %v", err)
-		client.Terminate()
-		writeError(w, "Slideshow failed to start", http.StatusInternalServerError)
+		removeUpload(filePath)
+		writeError(w, impress.NewError(impress.ERR_START_FAILED, "Slideshow failed to start"), http.StatusInternalServerError)
 		return
 	}
-	client.ListenAndServe()
-	setImpressClient(client)
 
 	toEncode := make(map[string]interface{})
 	toEncode["ownerUUID"] = uuid
+	toEncode["room"] = room.ID
+	status := http.StatusCreated
+	if position > 0 {
+		toEncode["position"] = position
+		status = http.StatusAccepted
+	} else {
+		toEncode["session"] = room.getImpressClient().GetStats().Session
+	}
 	encoded, _ := json.Marshal(toEncode)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	w.Write(encoded)
 
 }
 
+func GetPairing(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusNotFound)
+		return
+	}
+
+	client := room.getImpressClient()
+	session := client.GetStats().Session
+	remoteName, remotePIN := client.GetRemote()
+
+	toEncode := make(map[string]interface{})
+	toEncode["session"] = session
+	toEncode["remoteName"] = remoteName
+	// The PIN is only of use while Impress is asking for it
+	if session == impress.SESSION_PAIRING {
+		toEncode["pin"] = remotePIN
+	}
+	encoded, _ := json.Marshal(toEncode)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
 func ServeImpressController(w http.ResponseWriter, r *http.Request) {
-	if !isSlideShowRunning() {
-		writeError(w, "Slideshow not running", http.StatusBadRequest)
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusBadRequest)
 		return
 	}
 
-	client := getImpressClient()
+	client := room.getImpressClient()
+	ownerUUID := r.URL.Query().Get(OWNER_UUID)
+	isOwner := ownerUUID != "" && room.isSlideShowOwnerUUID(ownerUUID)
+	// Participants are only trusted with the ID of the resume token handed out to them, never with one they pick
+	resumeToken := r.URL.Query().Get(RESUME_TOKEN)
+	participantID, resumed := client.ResumeParticipant(resumeToken)
+	if !isOwner && client.IsBanned(participantID, r.RemoteAddr) {
+		Logger.InfoF("Rejected banned controller from %s in room %s", r.RemoteAddr, room.ID)
+		writeError(w, impress.NewError(impress.ERR_BANNED, "Banned from this presentation"), http.StatusForbidden)
+		return
+	}
 	if !client.HasControllerSpace() {
-		writeError(w, "Slideshow has reached the maximum number of controllers", http.StatusBadRequest)
+		rejectRoomFull(w, r, client.RoomFullError())
 		return
 	}
 
-	ownerUUID := r.URL.Query().Get(OWNER_UUID)
-	isOwner := false
-	if ownerUUID != "" {
+	invite := r.URL.Query().Get(INVITE_TOKEN)
+	role := impress.ROLE_VIEWER
+	if isOwner {
 		Logger.InfoF("Received owner uuid cookie with value %s", ownerUUID)
-		isOwner = isSlideShowOwnerUUID(ownerUUID)
+		role = impress.ROLE_OWNER
+	} else if invite != "" {
+		invitedRole, ok := client.GetInviteRole(invite)
+		if !ok {
+			writeError(w, impress.NewError(impress.ERR_INVALID_INVITE, "Invalid invite token"), http.StatusForbidden)
+			return
+		}
+		role = invitedRole
+	}
+
+	// A participant reconnecting keeps its ID, unless it is taken by a controller that is still connected
+	if !resumed || client.IsParticipantConnected(participantID) {
+		participantID, resumeToken = "", ""
+	}
+	participant := impress.Participant{
+		ID:          participantID,
+		Name:        participantName(r.URL.Query().Get(PARTICIPANT_NAME)),
+		RemoteAddr:  r.RemoteAddr,
+		ConnectedAt: time.Now(),
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -158,29 +239,57 @@ func ServeImpressController(w http.ResponseWriter, r *http.Request) {
 		Logger.WarningF("Failed to upgrade to socket connection from %s: %v", r.RemoteAddr, err)
 		return
 	}
-	Logger.InfoF("New socket controller from %s", r.RemoteAddr)
+	Logger.InfoF("New %s socket controller from %s in room %s", role, r.RemoteAddr, room.ID)
 
-	controller := impress.NewController(conn, isOwner)
+	controller := impress.NewController(conn, role, participant, resumeToken)
 	controller.StartPumping(client)
 }
 
-func Terminate(server *http.Server) {
-	if client := getImpressClient(); client != nil {
-		client.Terminate()
+// rejectRoomFull closes a websocket with Try Again Later, which clients can tell apart from a session that ended.
+// Requests that aren't websocket upgrades get a plain HTTP error
+func rejectRoomFull(w http.ResponseWriter, r *http.Request, err *impress.Error) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		writeError(w, err, http.StatusBadRequest)
+		return
 	}
-	server.Shutdown(nil)
+	conn, upgradeErr := upgrader.Upgrade(w, r, nil)
+	if upgradeErr != nil {
+		Logger.WarningF("Failed to upgrade to socket connection from %s: %v", r.RemoteAddr, upgradeErr)
+		return
+	}
+	impress.RejectConnection(conn, err, websocket.CloseTryAgainLater)
 }
 
-func getImpressClient() *impress.ImpressClient {
-	mu.Lock()
-	defer mu.Unlock()
-
-	return impressClient
+func participantName(name string) string {
+	runes := []rune(strings.TrimSpace(name))
+	if len(runes) > MAX_PARTICIPANT_NAME_LENGTH {
+		runes = runes[:MAX_PARTICIPANT_NAME_LENGTH]
+	}
+	return string(runes)
 }
 
-func setImpressClient(impr *impress.ImpressClient) {
-	mu.Lock()
-	defer mu.Unlock()
+func Terminate(server *http.Server) {
+	for _, room := range getRooms() {
+		room.clearQueue()
+		if client := room.getImpressClient(); client != nil {
+			client.End(impress.END_SERVER_SHUTDOWN)
+		}
+		room.saveState()
+	}
+	server.Shutdown(nil)
+}
 
-	impressClient = impr
+// connectPresentation pairs with impress in the background, since it blocks until the remote is authorised
+func connectPresentation(client *impress.ImpressClient) {
+	if err := client.OpenConnection(); err != nil {
+		// Stopping the presentation over HTTP during pairing is not a failure to reach impress
+		if client.IsTerminated() {
+			Logger.Info("Presentation was stopped while pairing with impress")
+			return
+		}
+		Logger.ErrorF("Failed to open impress remote connection: %v", err)
+		client.End(impress.END_IMPRESS_UNREACHABLE)
+		return
+	}
+	client.ListenAndServe()
 }
@@ -0,0 +1,639 @@
+package server
+
+import (
+	bytes "bytes"
+	json "encoding/json"
+	ioutil "io/ioutil"
+	multipart "mime/multipart"
+	http "net/http"
+	httptest "net/http/httptest"
+	os "os"
+	filepath "path/filepath"
+	strings "strings"
+	testing "testing"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	impresstest "github.com/DanInci/raspi-projector-backend/impress/impresstest"
+	log "github.com/apsdehal/go-logger"
+	mux "github.com/gorilla/mux"
+	websocket "github.com/gorilla/websocket"
+)
+
+const testTimeout = 5 * time.Second
+
+// Compound document header, which is what legacy .ppt files start with
+var pptContent = append([]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, make([]byte, 512)...)
+
+func TestMain(m *testing.M) {
+	logger, err := log.New("server-test", 0, ioutil.Discard)
+	if err != nil {
+		panic(err)
+	}
+	Logger = logger
+	impress.Logger = logger
+	impress.OfficeLauncher = impresstest.FakeOffice
+	impress.PairingDelay = 0
+	os.Exit(m.Run())
+}
+
+type testEnv struct {
+	impress *impresstest.Server
+	http    *httptest.Server
+	room    *Room
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	impressServer := impresstest.NewServer()
+	impress.Configure("soffice", "TestRemote", "1234", 2, 60, false, 3)
+	UploadDirectory = "uploads-" + strings.ReplaceAll(t.Name(), "/", "-")
+	StateDirectory = "state-" + strings.ReplaceAll(t.Name(), "/", "-")
+	if err := AddRoom("default", impressServer.URL, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	RegisterRoutes(r.PathPrefix(API_PREFIX).Subrouter())
+	RegisterRoutes(r)
+	httpServer := httptest.NewServer(r)
+
+	t.Cleanup(func() {
+		for _, room := range getRooms() {
+			room.clearQueue()
+			if client := room.getImpressClient(); client != nil {
+				client.Terminate()
+			}
+		}
+		resetRooms()
+		httpServer.Close()
+		impressServer.Close()
+	})
+	return &testEnv{impress: impressServer, http: httpServer, room: getRooms()[0]}
+}
+
+func resetRooms() {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	rooms = make(map[string]*Room)
+	roomIDs = make([]string, 0)
+}
+
+// addRoom adds another room backed by its own fake Impress server
+func (env *testEnv) addRoom(t *testing.T, id string) (*Room, *impresstest.Server) {
+	impressServer := impresstest.NewServer()
+	t.Cleanup(impressServer.Close)
+	if err := AddRoom(id, impressServer.URL, ""); err != nil {
+		t.Fatal(err)
+	}
+	return rooms[id], impressServer
+}
+
+func (env *testEnv) upload(t *testing.T, fileName string, content []byte) (*http.Response, map[string]interface{}) {
+	return env.uploadTo(t, "", fileName, content)
+}
+
+func (env *testEnv) uploadTo(t *testing.T, query string, fileName string, content []byte) (*http.Response, map[string]interface{}) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("fileName", fileName)
+	part, _ := writer.CreateFormFile("uploadFile", fileName)
+	part.Write(content)
+	writer.Close()
+
+	response, err := http.Post(env.http.URL+"/upload"+query, writer.FormDataContentType(), body)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	return response, decodeBody(t, response)
+}
+
+func (env *testEnv) get(t *testing.T, path string) (*http.Response, map[string]interface{}) {
+	response, err := http.Get(env.http.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return response, decodeBody(t, response)
+}
+
+func (env *testEnv) startPresentation(t *testing.T) string {
+	return env.startDeck(t, "deck.ppt", pptContent)
+}
+
+func (env *testEnv) startDeck(t *testing.T, fileName string, content []byte) string {
+	response, body := env.upload(t, fileName, content)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("upload returned %d: %v", response.StatusCode, body)
+	}
+	ownerUUID, _ := body["ownerUUID"].(string)
+	if ownerUUID == "" {
+		t.Fatalf("upload returned no owner uuid: %v", body)
+	}
+	if err := env.impress.WaitConnected(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	env.impress.StartSlideShow(3, 0)
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		if env.room.getImpressClient().GetStats().Status.IsRunning() {
+			return ownerUUID
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("slideshow was never reported as started")
+	return ""
+}
+
+// connect joins as a controller and returns its connection together with the ID announced by the server
+func (env *testEnv) connect(t *testing.T, query string) (*websocket.Conn, string) {
+	conn, info := env.join(t, query)
+	return conn, info["controllerID"].(string)
+}
+
+// join connects a controller and returns its controller info, which holds its resume token
+func (env *testEnv) join(t *testing.T, query string) (*websocket.Conn, map[string]interface{}) {
+	url := "ws" + strings.TrimPrefix(env.http.URL, "http") + "/control" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	info := readJSON(t, conn)
+	if info["command"] != impress.CONTROLLER_INFO {
+		t.Fatalf("expected controller info, got %v", info)
+	}
+	// Owners get the roster right after joining
+	if info["role"] == string(impress.ROLE_OWNER) {
+		if roster := readJSON(t, conn); roster["command"] != impress.ROSTER {
+			t.Fatalf("expected roster, got %v", roster)
+		}
+	}
+	return conn, info
+}
+
+func decodeBody(t *testing.T, response *http.Response) map[string]interface{} {
+	defer response.Body.Close()
+	decoded := make(map[string]interface{})
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decode response body: %v", err)
+	}
+	return decoded
+}
+
+func readJSON(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+	decoded := make(map[string]interface{})
+	if err := conn.ReadJSON(&decoded); err != nil {
+		t.Fatalf("read socket message: %v", err)
+	}
+	return decoded
+}
+
+// readCommand skips the messages that come before the next one with the command
+func readCommand(t *testing.T, conn *websocket.Conn, command string) map[string]interface{} {
+	for {
+		message := readJSON(t, conn)
+		if message["command"] == command || message["error"] != nil {
+			return message
+		}
+	}
+}
+
+func TestGetStatsWithoutSlideShow(t *testing.T) {
+	env := newTestEnv(t)
+
+	response, err := http.Get(env.http.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /stats returned %d, expected %d", response.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestUploadRejectsInvalidFileType(t *testing.T) {
+	env := newTestEnv(t)
+
+	response, body := env.upload(t, "notes.txt", []byte("plain text"))
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("upload returned %d, expected %d", response.StatusCode, http.StatusBadRequest)
+	}
+	if body["error"] != "Invalid file type" {
+		t.Errorf("unexpected error %v", body["error"])
+	}
+}
+
+func TestUploadAcceptsPPTX(t *testing.T) {
+	env := newTestEnv(t)
+	pptxContent := append([]byte("PK\x03\x04"), make([]byte, 512)...)
+
+	// Each extension has to match the content it stands for
+	if response, _ := env.upload(t, "deck.ppt", pptxContent); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("zip archive uploaded as .ppt returned %d", response.StatusCode)
+	}
+	if response, _ := env.upload(t, "deck.pptx", pptContent); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("compound document uploaded as .pptx returned %d", response.StatusCode)
+	}
+
+	response, body := env.upload(t, "deck.pptx", pptxContent)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("upload of a .pptx deck returned %d: %v", response.StatusCode, body)
+	}
+	if err := env.impress.WaitConnected(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadRejectsPathsInFileName(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, fileName := range []string{"../../../escaped.ppt", `..\escaped.ppt`, "nested/deck.ppt", ".."} {
+		response, body := env.upload(t, fileName, pptContent)
+		if response.StatusCode != http.StatusBadRequest || body["code"] != string(impress.ERR_INVALID_UPLOAD) {
+			t.Errorf("upload named %q returned %d: %v", fileName, response.StatusCode, body)
+		}
+	}
+	// Three levels up from the folder of an upload is the application directory
+	if _, err := os.Stat(filepath.Join(filepath.Dir(os.Args[0]), "escaped.ppt")); !os.IsNotExist(err) {
+		t.Errorf("upload was written outside of its folder: %v", err)
+	}
+	if env.room.isSlideShowRunning() {
+		t.Error("a rejected upload was started")
+	}
+}
+
+func TestUploadStartsPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	env.startPresentation(t)
+
+	response, err := http.Get(env.http.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := decodeBody(t, response)
+	status := stats["status"].(map[string]interface{})
+	if status["command"] != impress.SLIDE_SHOW_STARTED || status["totalSlides"] != 3.0 || status["currentSlide"] != 0.0 {
+		t.Errorf("unexpected stats status %v", status)
+	}
+
+	response, body := env.upload(t, "other.ppt", pptContent)
+	if response.StatusCode != http.StatusAccepted || body["position"] != 1.0 {
+		t.Errorf("second upload was not queued, returned %d: %v", response.StatusCode, body)
+	}
+}
+
+func TestUploadWaitsForPairing(t *testing.T) {
+	env := newTestEnv(t)
+	env.impress.RequirePIN = true
+
+	response, body := env.upload(t, "deck.ppt", pptContent)
+	if response.StatusCode != http.StatusCreated || body["session"] != string(impress.SESSION_CONNECTING) {
+		t.Fatalf("upload returned %d: %v", response.StatusCode, body)
+	}
+
+	deadline := time.Now().Add(testTimeout)
+	var pairing map[string]interface{}
+	for time.Now().Before(deadline) {
+		if _, pairing = env.get(t, "/pairing"); pairing["session"] == string(impress.SESSION_PAIRING) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pairing["pin"] != "1234" || pairing["remoteName"] != "TestRemote" {
+		t.Fatalf("pairing details not exposed while pairing: %v", pairing)
+	}
+
+	env.impress.Authorise()
+	for time.Now().Before(deadline) {
+		if _, pairing = env.get(t, "/pairing"); pairing["session"] == string(impress.SESSION_RUNNING) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pairing["session"] != string(impress.SESSION_RUNNING) {
+		t.Fatalf("session never started running: %v", pairing)
+	}
+	if _, ok := pairing["pin"]; ok {
+		t.Errorf("PIN exposed after pairing finished")
+	}
+}
+
+func TestOwnerControlsSlideShow(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+	env.impress.SendPreview(1, "cHJldmlldw==")
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	if message := readJSON(t, owner); message["command"] != impress.SLIDE_SHOW_STARTED {
+		t.Fatalf("unexpected initial message %v", message)
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	message := readJSON(t, owner)
+	if message["command"] != impress.SLIDE_UPDATED || message["currentSlide"] != 1.0 {
+		t.Fatalf("unexpected update %v", message)
+	}
+	if message["preview"] != "data:image/png;base64,cHJldmlldw==" {
+		t.Errorf("unexpected preview %v", message["preview"])
+	}
+}
+
+func TestViewerCannotControlSlideShow(t *testing.T) {
+	env := newTestEnv(t)
+	env.startPresentation(t)
+
+	viewer, _ := env.connect(t, "")
+	readJSON(t, viewer)
+
+	viewer.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	if message := readJSON(t, viewer); message["error"] != "Viewers can't control the presentation" {
+		t.Errorf("unexpected reply %v", message)
+	}
+}
+
+func TestControllerLimit(t *testing.T) {
+	env := newTestEnv(t)
+	env.startPresentation(t)
+
+	for i := 0; i < 2; i++ {
+		conn, _ := env.connect(t, "")
+		readJSON(t, conn)
+	}
+
+	url := "ws" + strings.TrimPrefix(env.http.URL, "http") + "/control"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if message := readJSON(t, conn); message["code"] != string(impress.ERR_ROOM_FULL) || message["details"].(map[string]interface{})["maxControllers"] != 2.0 {
+		t.Errorf("unexpected reply %v", message)
+	}
+	if closed := readClose(t, conn); closed.Code != websocket.CloseTryAgainLater || closed.Text != string(impress.ERR_ROOM_FULL) {
+		t.Errorf("connection over the controller limit was closed with %d %q", closed.Code, closed.Text)
+	}
+
+	if response, body := env.get(t, "/control"); response.StatusCode != http.StatusBadRequest || body["code"] != string(impress.ERR_ROOM_FULL) {
+		t.Errorf("request over the controller limit returned %d: %v", response.StatusCode, body)
+	}
+}
+
+func TestSetupRoomsNeedARemoteEach(t *testing.T) {
+	t.Cleanup(resetRooms)
+
+	if err := SetupRooms([]string{"default", " hall = ws://10.0.0.12:1599"}, "ws://localhost:1599", ""); err != nil {
+		t.Fatal(err)
+	}
+	if rooms["default"].RemoteURL != "ws://localhost:1599" || rooms["hall"].RemoteURL != "ws://10.0.0.12:1599" {
+		t.Errorf("unexpected remotes %s and %s", rooms["default"].RemoteURL, rooms["hall"].RemoteURL)
+	}
+
+	resetRooms()
+	if err := SetupRooms([]string{"default", "hall"}, "ws://localhost:1599", ""); err == nil {
+		t.Error("two rooms were set up on the same Impress remote")
+	}
+}
+
+func TestRoomsRunIndependently(t *testing.T) {
+	env := newTestEnv(t)
+	room, impressServer := env.addRoom(t, "second")
+	env.startPresentation(t)
+
+	response, body := env.uploadTo(t, "?room=second", "other.ppt", pptContent)
+	if response.StatusCode != http.StatusCreated || body["room"] != "second" {
+		t.Fatalf("upload to second room returned %d: %v", response.StatusCode, body)
+	}
+	secondOwner := body["ownerUUID"].(string)
+	if err := impressServer.WaitConnected(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if room.getImpressClient() == nil || room.getImpressClient() == env.room.getImpressClient() {
+		t.Fatal("rooms share the same impress client")
+	}
+	impressServer.StartSlideShow(5, 0)
+	deadline := time.Now().Add(testTimeout)
+	for !room.getImpressClient().GetStats().Status.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	owner, _ := env.connect(t, "?room=second&ownerUUID="+secondOwner)
+	readJSON(t, owner)
+	owner.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	if request, err := impressServer.NextRequest(testTimeout); err != nil || request.Command() != impress.TRANSITION_NEXT {
+		t.Fatalf("second room impress received %v: %v", request, err)
+	}
+
+	viewer, _ := env.connect(t, "?ownerUUID="+secondOwner)
+	readJSON(t, viewer)
+	viewer.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	if message := readJSON(t, viewer); message["error"] != "Viewers can't control the presentation" {
+		t.Errorf("owner of the second room controls the first one: %v", message)
+	}
+}
+
+func TestGetRooms(t *testing.T) {
+	env := newTestEnv(t)
+	env.addRoom(t, "second")
+	env.startPresentation(t)
+
+	response, err := http.Get(env.http.URL + "/rooms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var listed []map[string]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0]["id"] != "default" || listed[1]["id"] != "second" {
+		t.Fatalf("unexpected rooms %v", listed)
+	}
+	if listed[0]["isRunning"] != true || listed[1]["isRunning"] != false {
+		t.Errorf("unexpected room states %v", listed)
+	}
+
+	if response, _ := env.get(t, "/stats?room=missing"); response.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /stats of a missing room returned %d", response.StatusCode)
+	}
+}
+
+func TestOwnershipTransfer(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	owner, ownerID := env.connect(t, "?ownerUUID="+ownerUUID)
+	readJSON(t, owner)
+	colleague, colleagueID := env.connect(t, "")
+	readJSON(t, colleague)
+	readJSON(t, owner)
+
+	colleague.WriteJSON(map[string]string{"command": impress.TRANSFER_OWNERSHIP, "controllerID": colleagueID})
+	if message := readJSON(t, colleague); message["error"] != "Viewers can't control the presentation" {
+		t.Fatalf("viewer took over the presentation: %v", message)
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.TRANSFER_OWNERSHIP, "controllerID": colleagueID})
+	granted := readJSON(t, colleague)
+	newOwnerUUID, _ := granted["ownerUUID"].(string)
+	if granted["command"] != impress.OWNERSHIP_GRANTED || newOwnerUUID == "" || newOwnerUUID == ownerUUID {
+		t.Fatalf("unexpected grant %v", granted)
+	}
+	for _, conn := range []*websocket.Conn{colleague, owner} {
+		if message := readJSON(t, conn); message["command"] != impress.OWNER_CHANGED || message["controllerID"] != colleagueID {
+			t.Fatalf("unexpected ownership broadcast %v", message)
+		}
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	if message := readJSON(t, owner); message["error"] != "Viewers can't control the presentation" {
+		t.Fatalf("previous owner kept control: %v", message)
+	}
+	colleague.WriteJSON(map[string]string{"command": impress.TRANSFER_OWNERSHIP, "controllerID": ownerID})
+	readJSON(t, owner)
+
+	if env.room.isSlideShowOwnerUUID(ownerUUID) {
+		t.Error("old owner token was not revoked")
+	}
+}
+
+func TestInvitesGrantRoles(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	readJSON(t, owner)
+	owner.WriteJSON(map[string]string{"command": impress.CREATE_INVITE, "role": "owner"})
+	if message := readJSON(t, owner); message["error"] == nil {
+		t.Fatalf("owner invite was created: %v", message)
+	}
+	owner.WriteJSON(map[string]string{"command": impress.CREATE_INVITE, "role": string(impress.ROLE_CO_PRESENTER)})
+	invite := readJSON(t, owner)
+	token, _ := invite["token"].(string)
+	if invite["command"] != impress.INVITE_CREATED || token == "" {
+		t.Fatalf("unexpected invite %v", invite)
+	}
+
+	url := "ws" + strings.TrimPrefix(env.http.URL, "http") + "/control?invite=unknown"
+	if _, response, err := websocket.DefaultDialer.Dial(url, nil); err == nil || response.StatusCode != http.StatusForbidden {
+		t.Errorf("unknown invite was accepted")
+	}
+
+	coPresenter, _ := env.connect(t, "?invite="+token)
+	readJSON(t, coPresenter)
+	coPresenter.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	if request, err := env.impress.NextRequest(testTimeout); err != nil || request.Command() != impress.TRANSITION_NEXT {
+		t.Fatalf("co-presenter could not navigate: %v %v", request, err)
+	}
+	readJSON(t, coPresenter)
+	coPresenter.WriteJSON(map[string]string{"command": impress.PRESENTATION_STOP})
+	if message := readJSON(t, coPresenter); message["error"] != "Only the owner can control the presentation" {
+		t.Errorf("co-presenter stopped the presentation: %v", message)
+	}
+
+	_, stats := env.get(t, "/stats")
+	roles := stats["roles"].(map[string]interface{})
+	if roles["owner"] != 1.0 || roles["co_presenter"] != 1.0 || roles["viewer"] != 0.0 {
+		t.Errorf("unexpected role counts %v", roles)
+	}
+}
+
+func TestOwnerReceivesRoster(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	owner, ownerInfo := env.join(t, "?name=Alice&ownerUUID="+ownerUUID)
+	ownerID := ownerInfo["controllerID"].(string)
+	readJSON(t, owner)
+	viewer, viewerInfo := env.join(t, "?name=%20Bob%20")
+	viewerID := viewerInfo["controllerID"].(string)
+	readJSON(t, viewer)
+	if token, _ := viewerInfo["resumeToken"].(string); token == "" || token == viewerID {
+		t.Errorf("participant got no resume token of its own: %v", viewerInfo)
+	}
+
+	roster := readJSON(t, owner)
+	participants, _ := roster["participants"].([]interface{})
+	if roster["command"] != impress.ROSTER || len(participants) != 2 {
+		t.Fatalf("unexpected roster %v", roster)
+	}
+	joined := participants[1].(map[string]interface{})
+	if joined["controllerID"] != viewerID || joined["name"] != "Bob" || joined["role"] != string(impress.ROLE_VIEWER) {
+		t.Errorf("unexpected participant %v", joined)
+	}
+	if joined["remoteAddr"] == "" || joined["connectedAt"] == nil {
+		t.Errorf("participant misses its connection details: %v", joined)
+	}
+
+	viewer.Close()
+	roster = readJSON(t, owner)
+	if participants := roster["participants"].([]interface{}); len(participants) != 1 {
+		t.Fatalf("roster not updated on leave: %v", roster)
+	}
+
+	reconnected, reconnectedID := env.connect(t, "?name=Bob&resume="+viewerInfo["resumeToken"].(string))
+	if reconnectedID != viewerID {
+		t.Errorf("participant ID changed on reconnect from %s to %s", viewerID, reconnectedID)
+	}
+	readJSON(t, reconnected)
+	reconnected.Close()
+	readJSON(t, owner)
+	readJSON(t, owner)
+	taken, takenID := env.connect(t, "?resume="+ownerInfo["resumeToken"].(string))
+	if takenID == ownerID {
+		t.Error("participant ID of a connected controller was reused")
+	}
+	taken.Close()
+	waitForRoster(t, owner, 1)
+	// The public ID is no resume token, otherwise anyone seeing it could take the participant over
+	if _, impostorID := env.connect(t, "?resume="+viewerID); impostorID == viewerID {
+		t.Error("participant was resumed with its public ID")
+	}
+}
+
+func TestRequestControl(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	readJSON(t, owner)
+	viewer, viewerID := env.connect(t, "?name=Carol")
+	readJSON(t, viewer)
+	readJSON(t, owner)
+
+	viewer.WriteJSON(map[string]string{"command": impress.REQUEST_CONTROL})
+	if message := readJSON(t, viewer); message["command"] != impress.CONTROL_STATUS || message["state"] != string(impress.CONTROL_PENDING) {
+		t.Fatalf("unexpected reply to the request %v", message)
+	}
+	requests := readJSON(t, owner)
+	pending, _ := requests["requests"].([]interface{})
+	if requests["command"] != impress.CONTROL_REQUESTS || len(pending) != 1 || pending[0].(map[string]interface{})["controllerID"] != viewerID {
+		t.Fatalf("owner did not receive the request: %v", requests)
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.APPROVE_CONTROL, "controllerID": viewerID, "duration": "1"})
+	if message := readJSON(t, viewer); message["state"] != string(impress.CONTROL_GRANTED) || message["expiresAt"] == nil {
+		t.Fatalf("control was not granted: %v", message)
+	}
+	viewer.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	if request, err := env.impress.NextRequest(testTimeout); err != nil || request.Command() != impress.TRANSITION_NEXT {
+		t.Fatalf("granted viewer could not navigate: %v %v", request, err)
+	}
+	readJSON(t, viewer)
+
+	if message := readJSON(t, viewer); message["state"] != string(impress.CONTROL_REVOKED) {
+		t.Fatalf("control was not revoked: %v", message)
+	}
+	viewer.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	if message := readJSON(t, viewer); message["error"] != "Viewers can't control the presentation" {
+		t.Errorf("viewer kept control after it expired: %v", message)
+	}
+
+	viewer.WriteJSON(map[string]string{"command": impress.REQUEST_CONTROL})
+	readJSON(t, viewer)
+	owner.WriteJSON(map[string]string{"command": impress.DENY_CONTROL, "controllerID": viewerID})
+	if message := readJSON(t, viewer); message["state"] != string(impress.CONTROL_DENIED) {
+		t.Errorf("control was not denied: %v", message)
+	}
+}
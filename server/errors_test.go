@@ -0,0 +1,92 @@
+package server
+
+import (
+	http "net/http"
+	testing "testing"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	websocket "github.com/gorilla/websocket"
+)
+
+// expectError checks the body shared by both transports and returns its details
+func expectError(t *testing.T, body map[string]interface{}, code impress.ErrorCode) map[string]interface{} {
+	t.Helper()
+	if body["code"] != string(code) || body["message"] == "" || body["message"] != body["error"] {
+		t.Errorf("expected a %s error, got %v", code, body)
+		return map[string]interface{}{}
+	}
+	details, ok := body["details"].(map[string]interface{})
+	if !ok {
+		t.Errorf("%s error has no details: %v", code, body)
+	}
+	return details
+}
+
+func TestWebsocketErrorCodes(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	readJSON(t, owner)
+	viewer, _ := env.connect(t, "")
+	readJSON(t, viewer)
+
+	owner.WriteMessage(websocket.TextMessage, []byte("{"))
+	expectError(t, readCommand(t, owner, ""), impress.ERR_MALFORMED_REQUEST)
+
+	owner.WriteJSON(map[string]string{"command": "dance"})
+	expectError(t, readCommand(t, owner, ""), impress.ERR_UNKNOWN_COMMAND)
+
+	owner.WriteJSON(map[string]string{"command": impress.GO_TO_SLIDE, "index": "first"})
+	if details := expectError(t, readCommand(t, owner, ""), impress.ERR_INVALID_INDEX); details["field"] != "index" {
+		t.Errorf("invalid index error has details %v", details)
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.KICK, "controllerID": "nobody"})
+	expectError(t, readCommand(t, owner, ""), impress.ERR_NOT_FOUND)
+
+	viewer.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	expectError(t, readCommand(t, viewer, ""), impress.ERR_PERMISSION_DENIED)
+
+	viewer.WriteJSON(map[string]string{"command": impress.ASK_QUESTION, "text": "Why?"})
+	readCommand(t, viewer, impress.QUESTIONS)
+	viewer.WriteJSON(map[string]string{"command": impress.ASK_QUESTION, "text": "And how?"})
+	if details := expectError(t, readCommand(t, viewer, ""), impress.ERR_RATE_LIMITED); details["retryAfter"] != 10.0 {
+		t.Errorf("rate limit error has details %v", details)
+	}
+}
+
+func TestHTTPErrorCodes(t *testing.T) {
+	env := newTestEnv(t)
+
+	response, body := env.get(t, "/stats?room=missing")
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("stats of a missing room returned %d", response.StatusCode)
+	}
+	expectError(t, body, impress.ERR_ROOM_NOT_FOUND)
+
+	_, body = env.get(t, "/stats")
+	expectError(t, body, impress.ERR_NOT_RUNNING)
+
+	response, body = env.upload(t, "big.ppt", make([]byte, DEFAULT_MAX_UPLOAD_SIZE+1))
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("upload over the limit returned %d", response.StatusCode)
+	}
+	if details := expectError(t, body, impress.ERR_UPLOAD_TOO_LARGE); details["maxSize"] != float64(DEFAULT_MAX_UPLOAD_SIZE) {
+		t.Errorf("upload error has details %v", details)
+	}
+
+	_, body = env.upload(t, "notes.txt", pptContent)
+	expectError(t, body, impress.ERR_INVALID_FILE_TYPE)
+
+	ownerUUID := env.startPresentation(t)
+	response, body = env.control(t, "/presentation/goto/7", ownerUUID)
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("goto out of range returned %d", response.StatusCode)
+	}
+	if details := expectError(t, body, impress.ERR_INVALID_INDEX); details["totalSlides"] != 3.0 {
+		t.Errorf("out of range error has details %v", details)
+	}
+
+	_, body = env.control(t, "/presentation/next", "")
+	expectError(t, body, impress.ERR_OWNER_TOKEN_REQUIRED)
+}
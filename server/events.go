@@ -0,0 +1,98 @@
+package server
+
+import (
+	json "encoding/json"
+	errors "errors"
+	fmt "fmt"
+	http "net/http"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+// Period of the comments keeping idle event streams open through proxies
+const EVENTS_KEEP_ALIVE = 30 * time.Second
+
+// StreamEvents serves the slide status and the session lifecycle as Server-Sent Events, for viewers
+// that only watch. They don't count as controllers, so they aren't limited by their maximum number
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, impress.NewError(impress.ERR_NOT_SUPPORTED, "Streaming is not supported"), http.StatusInternalServerError)
+		return
+	}
+	// The stream outlives the write timeout of the server
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	events, unsubscribe := room.getImpressClient().Subscribe()
+	defer unsubscribe()
+	Logger.InfoF("New event subscriber from %s in room %s", r.RemoteAddr, room.ID)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(EVENTS_KEEP_ALIVE)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case message, ok := <-events:
+			if !ok {
+				return
+			}
+			encoded, err := encodeEvent(room.ID, message)
+			if err != nil {
+				Logger.ErrorF("Error encoding event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Command(), encoded); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// encodeEvent links previews by URL, unlike the websocket which inlines them
+func encodeEvent(roomID string, message impress.Message) ([]byte, error) {
+	toEncode := map[string]interface{}{"room": roomID}
+	switch message := message.(type) {
+	case impress.SessionStatus:
+		toEncode["state"] = message.State
+	case impress.SessionEnded:
+		toEncode["reason"] = message.Reason
+	case impress.SlideStatus:
+		switch status := message.Status.(type) {
+		case impress.SlideShowFinished:
+		case impress.SlideShowStarted:
+			toEncode["totalSlides"] = status.Total
+			toEncode["currentSlide"] = status.Current
+			toEncode["preview"] = previewURL(roomID, status.Current)
+		case impress.SlideUpdated:
+			toEncode["currentSlide"] = status.Current
+			toEncode["preview"] = previewURL(roomID, status.Current)
+		default:
+			return nil, errors.New("Failed to encode slide status")
+		}
+	default:
+		return nil, errors.New("Failed to encode event")
+	}
+	return json.Marshal(toEncode)
+}
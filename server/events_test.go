@@ -0,0 +1,79 @@
+package server
+
+import (
+	bufio "bufio"
+	json "encoding/json"
+	http "net/http"
+	strings "strings"
+	testing "testing"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+// readEvent returns the name and the data of the next event of the stream, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) (string, map[string]interface{}) {
+	name, data := "", make(map[string]interface{})
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+				t.Fatalf("decode event data %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	// Subscribers still get in once every controller slot is taken
+	env.connect(t, "?ownerUUID="+ownerUUID)
+	env.connect(t, "")
+	client := &http.Client{Timeout: testTimeout}
+	response, err := client.Get(env.http.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET /events returned %d with %s", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(response.Body)
+
+	if name, data := readEvent(t, reader); name != impress.SESSION_STATUS || data["state"] != string(impress.SESSION_RUNNING) {
+		t.Errorf("unexpected first event %s: %v", name, data)
+	}
+	if name, data := readEvent(t, reader); name != impress.SLIDE_SHOW_STARTED || data["totalSlides"] != 3.0 || data["preview"] != API_PREFIX+"/slides/0/preview?room=default" {
+		t.Errorf("unexpected slide event %s: %v", name, data)
+	}
+	if _, stats := env.get(t, "/stats"); stats["subscribers"] != 1.0 || stats["controllers"] != 2.0 {
+		t.Errorf("subscribers were not counted apart from controllers: %v", stats)
+	}
+
+	env.impress.UpdateSlide(1)
+	if name, data := readEvent(t, reader); name != impress.SLIDE_UPDATED || data["currentSlide"] != 1.0 || data["preview"] != API_PREFIX+"/slides/1/preview?room=default" {
+		t.Errorf("unexpected slide event %s: %v", name, data)
+	}
+
+	env.room.getImpressClient().Terminate()
+	readEvent(t, reader)
+	if name, data := readEvent(t, reader); name != impress.SESSION_ENDED || data["reason"] != string(impress.END_TERMINATED) {
+		t.Errorf("unexpected end event %s: %v", name, data)
+	}
+	if name, data := readEvent(t, reader); name != impress.SESSION_STATUS || data["state"] != string(impress.SESSION_TERMINATED) {
+		t.Errorf("unexpected last event %s: %v", name, data)
+	}
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Error("stream was not closed after the presentation ended")
+	}
+}
@@ -7,7 +7,7 @@ import (
 const (
 	DEFAULT_CORS_ALLOW_ORIGIN  = "*"
 	DEFAULT_CORS_ALLOW_METHODS = "POST, GET, OPTIONS, PUT, DELETE"
-	DEFAULT_CORS_ALLOW_HEADERS = "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Access-Token"
+	DEFAULT_CORS_ALLOW_HEADERS = "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Access-Token, X-Owner-UUID"
 )
 
 func CorsMiddleware(next http.Handler) http.Handler {
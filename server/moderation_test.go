@@ -0,0 +1,104 @@
+package server
+
+import (
+	http "net/http"
+	strings "strings"
+	testing "testing"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	websocket "github.com/gorilla/websocket"
+)
+
+func TestKickAndBan(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	viewer, viewerInfo := env.join(t, "?name=Troll")
+	viewerID := viewerInfo["controllerID"].(string)
+	resume := "?resume=" + viewerInfo["resumeToken"].(string)
+	readCommand(t, owner, impress.ROSTER)
+
+	owner.WriteJSON(map[string]string{"command": impress.LIST_CONTROLLERS})
+	if participants := readCommand(t, owner, impress.ROSTER)["participants"].([]interface{}); len(participants) != 2 {
+		t.Errorf("unexpected controllers %v", participants)
+	}
+	owner.WriteJSON(map[string]string{"command": impress.KICK, "controllerID": "unknown"})
+	if message := readCommand(t, owner, ""); message["error"] != "Controller not found" {
+		t.Errorf("unknown controller was kicked: %v", message)
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.KICK, "controllerID": viewerID, "reason": "Be nice"})
+	if closed := readClose(t, viewer); closed.Code != websocket.ClosePolicyViolation || closed.Text != "Be nice" {
+		t.Errorf("unexpected close %v", closed)
+	}
+	waitForRoster(t, owner, 1)
+
+	// Kicked controllers may come back, banned ones may not
+	viewer, _ = env.connect(t, resume)
+	readCommand(t, owner, impress.ROSTER)
+	owner.WriteJSON(map[string]string{"command": impress.BAN, "controllerID": viewerID})
+	if closed := readClose(t, viewer); closed.Code != websocket.ClosePolicyViolation || closed.Text != "Banned by the owner" {
+		t.Errorf("unexpected close %v", closed)
+	}
+	waitForRoster(t, owner, 1)
+	if status := env.dialStatus(t, resume); status != http.StatusForbidden {
+		t.Errorf("banned participant connected again: %d", status)
+	}
+
+	viewer, viewerID = env.connect(t, "")
+	readCommand(t, owner, impress.ROSTER)
+	owner.WriteJSON(map[string]string{"command": impress.BAN, "controllerID": viewerID, "by": impress.BAN_BY_ADDRESS})
+	readClose(t, viewer)
+	waitForRoster(t, owner, 1)
+	if status := env.dialStatus(t, ""); status != http.StatusForbidden {
+		t.Errorf("banned address connected again: %d", status)
+	}
+
+	// The owner is never locked out of its own presentation
+	owner.Close()
+	deadline := time.Now().Add(testTimeout)
+	for env.room.getImpressClient().GetStats().Controllers != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("owner never left")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	env.connect(t, "?ownerUUID="+ownerUUID)
+}
+
+// readClose reads until the server closes the connection
+func readClose(t *testing.T, conn *websocket.Conn) *websocket.CloseError {
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			closed, ok := err.(*websocket.CloseError)
+			if !ok {
+				t.Fatalf("connection was not closed properly: %v", err)
+			}
+			return closed
+		}
+	}
+}
+
+func waitForRoster(t *testing.T, conn *websocket.Conn, size int) {
+	for {
+		if participants := readCommand(t, conn, impress.ROSTER)["participants"].([]interface{}); len(participants) == size {
+			return
+		}
+	}
+}
+
+func (env *testEnv) dialStatus(t *testing.T, query string) int {
+	url := "ws" + strings.TrimPrefix(env.http.URL, "http") + "/control" + query
+	conn, response, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		conn.Close()
+		return http.StatusSwitchingProtocols
+	}
+	if response == nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	return response.StatusCode
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	testing "testing"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	websocket "github.com/gorilla/websocket"
+)
+
+func TestNotesReachOnlyTheOwner(t *testing.T) {
+	env := newTestEnv(t)
+	impress.Configure("soffice", "TestRemote", "1234", 3, 60, false, 3)
+	ownerUUID := env.startPresentation(t)
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	owner.WriteJSON(map[string]string{"command": impress.CREATE_INVITE, "role": string(impress.ROLE_CO_PRESENTER)})
+	token, _ := readCommand(t, owner, impress.INVITE_CREATED)["token"].(string)
+	coPresenter, _ := env.connect(t, "?invite="+token)
+	viewer, _ := env.connect(t, "")
+
+	env.impress.SendNotes(1, "Say hello")
+	env.impress.UpdateSlide(1)
+	if notes := readCommand(t, owner, impress.SLIDE_NOTES); notes["slide"] != 1.0 || notes["notes"] != "Say hello" {
+		t.Errorf("owner got notes %v", notes)
+	}
+
+	// The next slide has no notes, so whatever comes before it would be the notes of the previous one
+	env.impress.UpdateSlide(2)
+	for _, conn := range []*websocket.Conn{coPresenter, viewer} {
+		for {
+			message := readJSON(t, conn)
+			if message["command"] == impress.SLIDE_NOTES {
+				t.Errorf("notes reached a controller that isn't the owner: %v", message)
+			}
+			if message["command"] == impress.SLIDE_UPDATED && message["currentSlide"] == 2.0 {
+				break
+			}
+		}
+	}
+}
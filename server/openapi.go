@@ -0,0 +1,17 @@
+package server
+
+import (
+	_ "embed"
+	http "net/http"
+)
+
+// The OpenAPI document of the versioned API. TestOpenAPI keeps it in sync with the routes and the responses
+//
+//go:embed openapi.json
+var openAPI []byte
+
+func GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPI)
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	json "encoding/json"
+	fmt "fmt"
+	http "net/http"
+	strings "strings"
+	testing "testing"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	mux "github.com/gorilla/mux"
+)
+
+type openAPISpec map[string]interface{}
+
+func loadOpenAPI(t *testing.T) openAPISpec {
+	var spec openAPISpec
+	if err := json.Unmarshal(openAPI, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return spec
+}
+
+// schema returns the schema of a JSON response documented for the operation
+func (spec openAPISpec) schema(t *testing.T, path string, method string, status int) map[string]interface{} {
+	operation, ok := spec.lookup("paths", path, strings.ToLower(method)).(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s %s is not documented", method, path)
+	}
+	schema, ok := lookup(operation, "responses", fmt.Sprint(status), "content", "application/json", "schema").(map[string]interface{})
+	if !ok {
+		t.Fatalf("JSON response %d of %s %s is not documented", status, method, path)
+	}
+	return schema
+}
+
+func (spec openAPISpec) lookup(keys ...string) interface{} {
+	return lookup(map[string]interface{}(spec), keys...)
+}
+
+func lookup(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// checkSchema fails for values missing required properties, having undocumented ones or of another type
+func (spec openAPISpec) checkSchema(t *testing.T, schema map[string]interface{}, value interface{}, at string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, ok := spec.lookup(strings.Split(strings.TrimPrefix(ref, "#/"), "/")...).(map[string]interface{})
+		if !ok {
+			t.Fatalf("%s: unresolved reference %s", at, ref)
+		}
+		schema = resolved
+	}
+	if value == nil {
+		if schema["nullable"] != true {
+			t.Errorf("%s: null is not documented as nullable", at)
+		}
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: expected an object, got %v", at, value)
+			return
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, key := range required {
+			if _, ok := object[key.(string)]; !ok {
+				t.Errorf("%s: required property %s is missing", at, key)
+			}
+		}
+		for key, property := range object {
+			if propertySchema, ok := properties[key].(map[string]interface{}); ok {
+				spec.checkSchema(t, propertySchema, property, at+"."+key)
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				spec.checkSchema(t, additional, property, at+"."+key)
+			} else {
+				t.Errorf("%s: property %s is not documented", at, key)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			t.Errorf("%s: expected an array, got %v", at, value)
+			return
+		}
+		for i, item := range array {
+			spec.checkSchema(t, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", at, i))
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			t.Errorf("%s: expected a string, got %v", at, value)
+		} else if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, str) {
+			t.Errorf("%s: %s is not one of the documented values", at, str)
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int(number)) {
+			t.Errorf("%s: expected an integer, got %v", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: expected a boolean, got %v", at, value)
+		}
+	default:
+		t.Errorf("%s: unsupported schema type %v", at, schema["type"])
+	}
+}
+
+func containsValue(values []interface{}, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOpenAPIListsEveryErrorCode(t *testing.T) {
+	spec := loadOpenAPI(t)
+	enum, _ := spec.lookup("components", "schemas", "Error", "properties", "code", "enum").([]interface{})
+	if len(enum) != len(impress.ErrorCodes) {
+		t.Errorf("%d error codes are documented, expected %d", len(enum), len(impress.ErrorCodes))
+	}
+	for _, code := range impress.ErrorCodes {
+		if !containsValue(enum, string(code)) {
+			t.Errorf("error code %s is not documented", code)
+		}
+	}
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	spec := loadOpenAPI(t)
+	r := mux.NewRouter()
+	RegisterRoutes(r)
+
+	registered := make(map[string]bool)
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			registered[strings.ToLower(method)+" "+path] = true
+			if spec.lookup("paths", path, strings.ToLower(method)) == nil {
+				t.Errorf("%s %s is not documented", method, path)
+			}
+		}
+		return nil
+	})
+	for path, operations := range spec["paths"].(map[string]interface{}) {
+		for method := range operations.(map[string]interface{}) {
+			if !registered[method+" "+path] {
+				t.Errorf("%s %s is documented but not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDescribesResponses(t *testing.T) {
+	env := newTestEnv(t)
+	spec := loadOpenAPI(t)
+	check := func(method string, path string, query string, ownerUUID string, status int) map[string]interface{} {
+		request, _ := http.NewRequest(method, env.http.URL+API_PREFIX+strings.NewReplacer("{index}", "0", "{action}", "next").Replace(path)+query, nil)
+		if ownerUUID != "" {
+			request.Header.Set(OWNER_UUID_HEADER, ownerUUID)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		if response.StatusCode != status {
+			t.Fatalf("%s %s returned %d, expected %d", method, path, response.StatusCode, status)
+		}
+		var body interface{}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		response.Body.Close()
+		spec.checkSchema(t, spec.schema(t, path, method, status), body, method+" "+path)
+		object, _ := body.(map[string]interface{})
+		return object
+	}
+
+	check("GET", "/rooms", "", "", http.StatusOK)
+	check("GET", "/stats", "", "", http.StatusNotFound)
+
+	ownerUUID := env.startPresentation(t)
+	_, queued := env.uploadTo(t, "", "next.ppt", pptContent)
+	spec.checkSchema(t, spec.schema(t, "/upload", "POST", http.StatusAccepted), queued, "POST /upload")
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	owner.WriteJSON(map[string]string{"command": impress.ASK_QUESTION, "text": "Why?"})
+	readCommand(t, owner, impress.QUESTIONS)
+	env.impress.SendPreview(0, "cHJldmlldw==")
+	deadline := time.Now().Add(testTimeout)
+	for _, ok := env.room.getImpressClient().GetPreview(0); !ok; _, ok = env.room.getImpressClient().GetPreview(0) {
+		if time.Now().After(deadline) {
+			t.Fatal("preview never arrived")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	check("GET", "/rooms", "", "", http.StatusOK)
+	check("GET", "/stats", "", "", http.StatusOK)
+	check("GET", "/queue", "?ownerUUID="+queued["ownerUUID"].(string), "", http.StatusOK)
+	check("GET", "/pairing", "", "", http.StatusOK)
+	slides := check("GET", "/slides", "", "", http.StatusOK)
+	// Preview links are followed by clients as they are, so they have to point into the versioned API
+	preview := slides["slides"].([]interface{})[0].(map[string]interface{})["preview"].(string)
+	if !strings.HasPrefix(preview, API_PREFIX+"/slides/0/preview?") {
+		t.Errorf("preview link %q is outside %s", preview, API_PREFIX)
+	}
+	if response, err := http.Get(env.http.URL + preview); err != nil || response.StatusCode != http.StatusOK {
+		t.Errorf("preview link %q does not resolve: %v", preview, err)
+	} else {
+		response.Body.Close()
+	}
+	check("GET", "/questions", "?ownerUUID="+ownerUUID, "", http.StatusOK)
+	check("GET", "/presentation/slides", "", ownerUUID, http.StatusOK)
+	check("POST", "/presentation/{action}", "", "", http.StatusUnauthorized)
+	check("POST", "/presentation/goto/{index}", "", ownerUUID, http.StatusOK)
+	check("DELETE", "/presentation", "", ownerUUID, http.StatusOK)
+
+	var document map[string]interface{}
+	response, err := http.Get(env.http.URL + API_PREFIX + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil || document["openapi"] != "3.0.3" {
+		t.Errorf("openapi.json is not served: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	testing "testing"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	websocket "github.com/gorilla/websocket"
+)
+
+func TestPointer(t *testing.T) {
+	env := newTestEnv(t)
+	impress.Configure("soffice", "TestRemote", "1234", 3, 60, true, 3)
+	ownerUUID := env.startPresentation(t)
+	env.impress.UpdateSlide(2)
+	waitForSlide(t, env.room.getImpressClient(), 2)
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	owner.WriteJSON(map[string]string{"command": impress.CREATE_INVITE, "role": string(impress.ROLE_CO_PRESENTER)})
+	token, _ := readCommand(t, owner, impress.INVITE_CREATED)["token"].(string)
+	coPresenter, _ := env.connect(t, "?invite="+token)
+	viewer, _ := env.connect(t, "")
+
+	owner.WriteJSON(map[string]interface{}{"command": impress.POINTER_STARTED, "x": 1.5, "y": 0.25})
+	if message := readCommand(t, owner, ""); message["code"] != string(impress.ERR_INVALID_ARGUMENT) {
+		t.Errorf("pointer outside of the slide was accepted: %v", message)
+	}
+
+	// The last slide is shown, which drops moving on but not the pointer
+	owner.WriteJSON(map[string]interface{}{"command": impress.POINTER_STARTED, "x": 0.5, "y": 0.25})
+	if request, err := env.impress.NextRequest(testTimeout); err != nil || request != (impress.PointerStarted{X: 0.5, Y: 0.25}) {
+		t.Fatalf("pointer of the owner did not reach impress: %v %v", request, err)
+	}
+	for _, conn := range []*websocket.Conn{coPresenter, viewer} {
+		if pointer := readCommand(t, conn, impress.POINTER_STARTED); pointer["x"] != 0.5 || pointer["y"] != 0.25 {
+			t.Errorf("pointer was not shared: %v", pointer)
+		}
+	}
+	owner.WriteJSON(map[string]string{"command": impress.TRANSITION_NEXT})
+	owner.WriteJSON(map[string]string{"command": impress.POINTER_DISMISSED})
+	if request, err := env.impress.NextRequest(testTimeout); err != nil || request != (impress.PointerDismissed{}) {
+		t.Errorf("expected the pointer to be dismissed after moving past the last slide was dropped, got %v %v", request, err)
+	}
+	readCommand(t, coPresenter, impress.POINTER_DISMISSED)
+	readCommand(t, viewer, impress.POINTER_DISMISSED)
+
+	// The pointer of a co-presenter is shown to everybody else, the owner included
+	coPresenter.WriteJSON(map[string]interface{}{"command": impress.POINTER_COORDINATION, "x": 0.75, "y": 0.5})
+	if request, err := env.impress.NextRequest(testTimeout); err != nil || request != (impress.PointerCoordination{X: 0.75, Y: 0.5}) {
+		t.Fatalf("pointer of the co-presenter did not reach impress: %v %v", request, err)
+	}
+	for _, conn := range []*websocket.Conn{owner, viewer} {
+		if pointer := readCommand(t, conn, impress.POINTER_COORDINATION); pointer["x"] != 0.75 || pointer["y"] != 0.5 {
+			t.Errorf("pointer of the co-presenter was not shared: %v", pointer)
+		}
+	}
+	owner.WriteJSON(map[string]string{"command": impress.POINTER_DISMISSED})
+	for message := readJSON(t, coPresenter); message["command"] != impress.POINTER_DISMISSED; message = readJSON(t, coPresenter) {
+		if message["command"] == impress.POINTER_COORDINATION {
+			t.Errorf("co-presenter got its own pointer back: %v", message)
+		}
+	}
+}
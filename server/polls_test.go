@@ -0,0 +1,98 @@
+package server
+
+import (
+	fmt "fmt"
+	strings "strings"
+	testing "testing"
+	utf8 "unicode/utf8"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	websocket "github.com/gorilla/websocket"
+)
+
+func TestPolls(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	readJSON(t, owner)
+	viewer, _ := env.connect(t, "")
+	readJSON(t, viewer)
+
+	viewer.WriteJSON(map[string]interface{}{"command": impress.OPEN_POLL, "question": "Tea?", "options": []string{"Yes", "No"}})
+	if message := readJSON(t, viewer); message["error"] != "Viewers can't control the presentation" {
+		t.Errorf("viewer opened a poll: %v", message)
+	}
+	owner.WriteJSON(map[string]interface{}{"command": impress.OPEN_POLL, "question": "Tea?", "options": []string{"Yes"}})
+	if message := readCommand(t, owner, ""); message["error"] != "Poll must have between 2 and 10 options" {
+		t.Errorf("poll with a single option was opened: %v", message)
+	}
+
+	owner.WriteJSON(map[string]interface{}{"command": impress.OPEN_POLL, "question": "Tea?", "options": []string{"Yes", "No"}})
+	readCommand(t, owner, impress.POLL)
+	poll := readCommand(t, viewer, impress.POLL)
+	if poll["question"] != "Tea?" || poll["isOpen"] != true || len(poll["options"].([]interface{})) != 2 {
+		t.Fatalf("unexpected poll %v", poll)
+	}
+	pollID := poll["pollID"].(string)
+
+	viewer.WriteJSON(map[string]interface{}{"command": impress.VOTE, "pollID": pollID, "option": 1})
+	tallied := readCommand(t, owner, impress.POLL)
+	if tallies := tallied["tallies"].([]interface{}); tallies[0] != 0.0 || tallies[1] != 1.0 || tallied["totalVotes"] != 1.0 {
+		t.Errorf("vote was not counted: %v", tallied)
+	}
+	readCommand(t, viewer, impress.POLL)
+	viewer.WriteJSON(map[string]interface{}{"command": impress.VOTE, "pollID": pollID, "option": 0})
+	if message := readJSON(t, viewer); message["error"] != "Already voted in this poll" {
+		t.Errorf("second vote was accepted: %v", message)
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.CLOSE_POLL})
+	if closed := readCommand(t, viewer, impress.POLL); closed["isOpen"] != false {
+		t.Errorf("poll is still open: %v", closed)
+	}
+
+	viewer.WriteJSON(map[string]string{"command": impress.REACT, "emoji": "🎉"})
+	viewer.WriteJSON(map[string]string{"command": impress.REACT, "emoji": "🎉"})
+	// Both reactions usually arrive in the same period, but the ticker may fire in between
+	for received := 0.0; received < 2; {
+		reactions := readCommand(t, owner, impress.REACTIONS)
+		count, ok := reactions["counts"].(map[string]interface{})["🎉"].(float64)
+		if !ok {
+			t.Fatalf("unexpected reactions %v", reactions)
+		}
+		received += count
+	}
+	viewer.WriteJSON(map[string]string{"command": impress.REACT, "emoji": "💩"})
+	if message := readCommand(t, viewer, ""); message["error"] != "Reaction not allowed" {
+		t.Errorf("unknown reaction was accepted: %v", message)
+	}
+}
+
+func TestLargestPollAndQuestion(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	readJSON(t, owner)
+
+	// Clients may escape every rune, which is the largest encoding of a valid request
+	escaped := func(runes int) string {
+		return `"` + strings.Repeat(`\ud83c\udf89`, runes) + `"`
+	}
+	options := make([]string, impress.MAX_POLL_OPTIONS)
+	for i := range options {
+		options[i] = escaped(impress.MAX_POLL_OPTION_LENGTH)
+	}
+	owner.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"command":%q,"question":%s,"options":[%s]}`,
+		impress.OPEN_POLL, escaped(impress.MAX_QUESTION_LENGTH), strings.Join(options, ","))))
+	poll := readCommand(t, owner, impress.POLL)
+	if options := poll["options"].([]interface{}); len(options) != impress.MAX_POLL_OPTIONS || utf8.RuneCountInString(options[0].(string)) != impress.MAX_POLL_OPTION_LENGTH {
+		t.Errorf("unexpected poll %v", poll)
+	}
+
+	owner.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"command":%q,"text":%s}`, impress.ASK_QUESTION, escaped(impress.MAX_QUESTION_LENGTH))))
+	questions := readCommand(t, owner, impress.QUESTIONS)["questions"].([]interface{})
+	if text := questions[0].(map[string]interface{})["text"].(string); utf8.RuneCountInString(text) != impress.MAX_QUESTION_LENGTH {
+		t.Errorf("question was cut to %d runes", utf8.RuneCountInString(text))
+	}
+}
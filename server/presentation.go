@@ -0,0 +1,169 @@
+package server
+
+import (
+	json "encoding/json"
+	http "net/http"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	mux "github.com/gorilla/mux"
+)
+
+// Header carrying the owner token for the REST control endpoints, since scripts rarely manage cookies
+const OWNER_UUID_HEADER = "X-Owner-UUID"
+
+// Actions of the REST control endpoints and the controller commands they stand for
+var presentationCommands = map[string]string{
+	"next":     impress.TRANSITION_NEXT,
+	"previous": impress.TRANSITION_PREVIOUS,
+	"goto":     impress.GO_TO_SLIDE,
+	"blank":    impress.PRESENTATION_BLANK_SCREEN,
+	"resume":   impress.PRESENTATION_RESUME,
+	"stop":     impress.PRESENTATION_STOP,
+}
+
+// ControlPresentation runs a controller command on behalf of the owner and answers with the resulting slide status
+func ControlPresentation(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !authorizeOwner(w, r, room) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	action := vars["action"]
+	if _, ok := vars["index"]; ok {
+		action = "goto"
+	}
+	command, ok := presentationCommands[action]
+	if !ok {
+		writeError(w, impress.NewError(impress.ERR_UNKNOWN_COMMAND, "Command not recognized"), http.StatusNotFound)
+		return
+	}
+	fields := map[string]string{"command": command}
+	if index, ok := vars["index"]; ok {
+		fields["index"] = index
+	}
+	request, err := impress.DecodeCommand(fields)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	status, err := room.getImpressClient().Execute(request)
+	if err != nil && impress.AsError(err).Code == impress.ERR_INVALID_INDEX {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		writeError(w, err, http.StatusConflict)
+		return
+	}
+	encodedStatus, err := encodeSlideShowStatus(status)
+	if err != nil {
+		Logger.ErrorF("Error encoding slide status: %v", err)
+		writeError(w, impress.NewError(impress.ERR_INTERNAL, "Failed to encode slide status"), http.StatusInternalServerError)
+		return
+	}
+
+	encoded, _ := json.Marshal(map[string]interface{}{
+		"room":   room.ID,
+		"status": encodedStatus,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+// StopPresentation ends the presentation on behalf of the owner and answers with the summary of the session
+func StopPresentation(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !authorizeOwner(w, r, room) {
+		return
+	}
+
+	summary := room.getImpressClient().End(impress.END_STOPPED_BY_OWNER)
+	Logger.InfoF("Presentation of room %s was stopped over HTTP", room.ID)
+
+	encoded, _ := json.Marshal(map[string]interface{}{
+		"room":              room.ID,
+		"name":              summary.Name,
+		"reason":            summary.Reason,
+		"startedAt":         summary.StartedAt,
+		"endedAt":           summary.EndedAt,
+		"totalSlides":       summary.TotalSlides,
+		"visitedSlides":     summary.VisitedSlides,
+		"controllers":       summary.Controllers,
+		"questions":         summary.Questions,
+		"answeredQuestions": summary.AnsweredQuestions,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+// authorizeOwner checks the owner token in the header, writing the error if the request isn't from the owner
+func authorizeOwner(w http.ResponseWriter, r *http.Request, room *Room) bool {
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusNotFound)
+		return false
+	}
+	ownerUUID := r.Header.Get(OWNER_UUID_HEADER)
+	if ownerUUID == "" {
+		writeError(w, impress.NewError(impress.ERR_OWNER_TOKEN_REQUIRED, OWNER_UUID_HEADER+" header required"), http.StatusUnauthorized)
+		return false
+	}
+	if !room.isSlideShowOwnerUUID(ownerUUID) {
+		writeError(w, impress.NewError(impress.ERR_NOT_OWNER, "Invalid owner token"), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// GetPresentationSlides lists the slides of the deck for a slide picker. Notes are only shown to the owner,
+// who identifies with the owner token in the header or in the query
+func GetPresentationSlides(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusNotFound)
+		return
+	}
+	ownerUUID := r.Header.Get(OWNER_UUID_HEADER)
+	if ownerUUID == "" {
+		ownerUUID = r.URL.Query().Get(OWNER_UUID)
+	}
+	isOwner := ownerUUID != "" && room.isSlideShowOwnerUUID(ownerUUID)
+
+	client := room.getImpressClient()
+	slides := make([]map[string]interface{}, 0)
+	for _, slide := range client.GetSlides() {
+		encoded := map[string]interface{}{
+			"index":   slide.Index,
+			"title":   slide.Title,
+			"preview": nil,
+			"visited": slide.Visited,
+		}
+		if slide.HasPreview {
+			encoded["preview"] = previewURL(room.ID, slide.Index)
+		}
+		if isOwner {
+			encoded["notes"] = slide.Notes
+		}
+		slides = append(slides, encoded)
+	}
+
+	encoded, _ := json.Marshal(map[string]interface{}{
+		"room":         room.ID,
+		"currentSlide": client.GetStats().Status.CurrentSlide,
+		"slides":       slides,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	http "net/http"
+	testing "testing"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	impresstest "github.com/DanInci/raspi-projector-backend/impress/impresstest"
+	websocket "github.com/gorilla/websocket"
+)
+
+func (env *testEnv) control(t *testing.T, path string, ownerUUID string) (*http.Response, map[string]interface{}) {
+	return env.asOwner(t, "POST", path, ownerUUID)
+}
+
+func (env *testEnv) asOwner(t *testing.T, method string, path string, ownerUUID string) (*http.Response, map[string]interface{}) {
+	request, _ := http.NewRequest(method, env.http.URL+path, nil)
+	if ownerUUID != "" {
+		request.Header.Set(OWNER_UUID_HEADER, ownerUUID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return response, decodeBody(t, response)
+}
+
+func TestControlPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	if response, _ := env.control(t, "/presentation/next", ""); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("command without owner token returned %d", response.StatusCode)
+	}
+	if response, _ := env.control(t, "/presentation/next", "someone"); response.StatusCode != http.StatusForbidden {
+		t.Errorf("command with a wrong owner token returned %d", response.StatusCode)
+	}
+
+	response, body := env.control(t, "/presentation/next", ownerUUID)
+	if status := body["status"].(map[string]interface{}); response.StatusCode != http.StatusOK || status["currentSlide"] != 1.0 {
+		t.Errorf("next returned %d: %v", response.StatusCode, body)
+	}
+	if request, _ := env.impress.NextRequest(testTimeout); request != (impress.TransitionNext{}) {
+		t.Errorf("impress received %v", request)
+	}
+
+	response, body = env.control(t, "/presentation/goto/2", ownerUUID)
+	if status := body["status"].(map[string]interface{}); response.StatusCode != http.StatusOK || status["currentSlide"] != 2.0 {
+		t.Errorf("goto returned %d: %v", response.StatusCode, body)
+	}
+	env.impress.NextRequest(testTimeout)
+	if response, body := env.control(t, "/presentation/goto/-1", ownerUUID); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("goto to a negative index returned %d: %v", response.StatusCode, body)
+	}
+	if response, body := env.control(t, "/presentation/goto/7", ownerUUID); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("goto past the last slide returned %d: %v", response.StatusCode, body)
+	}
+	if response, _ := env.control(t, "/presentation/jump", ownerUUID); response.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown command returned %d", response.StatusCode)
+	}
+
+	if response, body := env.control(t, "/presentation/blank", ownerUUID); response.StatusCode != http.StatusOK {
+		t.Errorf("blank returned %d: %v", response.StatusCode, body)
+	}
+	if request, _ := env.impress.NextRequest(testTimeout); request != (impress.PresentationBlankScreen{}) {
+		t.Errorf("impress received %v", request)
+	}
+
+	response, body = env.control(t, "/presentation/stop", ownerUUID)
+	if status := body["status"].(map[string]interface{}); response.StatusCode != http.StatusOK || status["state"] != "finished" {
+		t.Errorf("stop returned %d: %v", response.StatusCode, body)
+	}
+}
+
+func TestGetPresentationSlides(t *testing.T) {
+	env := newTestEnv(t)
+	MaxUploadSize = 1024 * 1024
+	t.Cleanup(func() { MaxUploadSize = DEFAULT_MAX_UPLOAD_SIZE })
+	ownerUUID := env.startDeck(t, "deck.pptx", impresstest.Deck("Welcome", "", "Questions?"))
+	env.impress.SendPreview(0, "cHJldmlldw==")
+	env.impress.SendNotes(0, "Say hello")
+	env.impress.UpdateSlide(2)
+	waitForSlide(t, env.room.getImpressClient(), 2)
+
+	response, body := env.get(t, "/presentation/slides")
+	if response.StatusCode != http.StatusOK || body["currentSlide"] != 2.0 {
+		t.Fatalf("GET /presentation/slides returned %d: %v", response.StatusCode, body)
+	}
+	slides := body["slides"].([]interface{})
+	if len(slides) != 3 {
+		t.Fatalf("unexpected slides %v", slides)
+	}
+	first, second, third := slides[0].(map[string]interface{}), slides[1].(map[string]interface{}), slides[2].(map[string]interface{})
+	if first["title"] != "Welcome" || first["preview"] != API_PREFIX+"/slides/0/preview?room=default" || first["visited"] != true {
+		t.Errorf("unexpected first slide %v", first)
+	}
+	if second["title"] != "" || second["preview"] != nil || second["visited"] != false {
+		t.Errorf("unexpected second slide %v", second)
+	}
+	if third["title"] != "Questions?" || third["visited"] != true {
+		t.Errorf("unexpected third slide %v", third)
+	}
+	if _, ok := first["notes"]; ok {
+		t.Errorf("notes were shown without the owner token: %v", first)
+	}
+
+	_, body = env.get(t, "/presentation/slides?ownerUUID="+ownerUUID)
+	if notes := body["slides"].([]interface{})[0].(map[string]interface{})["notes"]; notes != "Say hello" {
+		t.Errorf("owner got notes %v", notes)
+	}
+}
+
+func waitForSlide(t *testing.T, client *impress.ImpressClient, slide int) {
+	deadline := time.Now().Add(testTimeout)
+	for client.GetStats().Status.CurrentSlide != slide {
+		if time.Now().After(deadline) {
+			t.Fatalf("slide %d was never shown", slide)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+	env.connect(t, "?ownerUUID="+ownerUUID)
+	viewer, _ := env.connect(t, "")
+
+	if response, body := env.asOwner(t, "DELETE", "/presentation", "someone"); response.StatusCode != http.StatusForbidden || body["code"] != string(impress.ERR_NOT_OWNER) {
+		t.Errorf("stop with a wrong owner token returned %d: %v", response.StatusCode, body)
+	}
+
+	response, summary := env.asOwner(t, "DELETE", "/presentation", ownerUUID)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("stop returned %d: %v", response.StatusCode, summary)
+	}
+	if summary["reason"] != string(impress.END_STOPPED_BY_OWNER) || summary["totalSlides"] != 3.0 || summary["visitedSlides"] != 1.0 || summary["controllers"] != 2.0 {
+		t.Errorf("unexpected summary %v", summary)
+	}
+	if ended := readCommand(t, viewer, impress.SESSION_ENDED); ended["reason"] != string(impress.END_STOPPED_BY_OWNER) {
+		t.Errorf("controllers were told %v", ended)
+	}
+	if closed := readClose(t, viewer); closed.Code != websocket.CloseNormalClosure || closed.Text != string(impress.END_STOPPED_BY_OWNER) {
+		t.Errorf("controllers were disconnected with %d %q", closed.Code, closed.Text)
+	}
+
+	if response, _ := env.asOwner(t, "DELETE", "/presentation", ownerUUID); response.StatusCode != http.StatusNotFound {
+		t.Errorf("stopping again returned %d", response.StatusCode)
+	}
+}
+
+func TestStopWhileImpressSendsEvents(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+	env.impress.SendNotes(1, "Speaker notes")
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	viewer, _ := env.connect(t, "")
+	readJSON(t, viewer)
+
+	// Events that were already read from Impress are still handled after the session ended
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			if env.impress.UpdateSlide(i%2) != nil {
+				return
+			}
+		}
+	}()
+	waitForSlide(t, env.room.getImpressClient(), 1)
+	if response, summary := env.asOwner(t, "DELETE", "/presentation", ownerUUID); response.StatusCode != http.StatusOK {
+		t.Fatalf("stop returned %d: %v", response.StatusCode, summary)
+	}
+	<-done
+	owner.WriteJSON(map[string]string{"command": impress.LIST_CONTROLLERS})
+
+	if closed := readClose(t, viewer); closed.Code != websocket.CloseNormalClosure {
+		t.Errorf("viewer was disconnected with %d %q", closed.Code, closed.Text)
+	}
+}
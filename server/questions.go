@@ -0,0 +1,98 @@
+package server
+
+import (
+	csv "encoding/csv"
+	json "encoding/json"
+	fmt "fmt"
+	http "net/http"
+	strconv "strconv"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+// Number of ended sessions per room whose questions can still be exported
+const MAX_FINISHED_SESSIONS = 10
+
+type finishedSession struct {
+	ownerUUID string
+	questions []impress.Question
+	endedAt   time.Time
+}
+
+// ExportQuestions returns every question of a session to its owner, as JSON or as CSV with format=csv.
+// It keeps working after the session ended
+func ExportQuestions(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+
+	ownerUUID := r.URL.Query().Get(OWNER_UUID)
+	questions, ok := room.getQuestions(ownerUUID)
+	if ownerUUID == "" || !ok {
+		writeError(w, impress.NewError(impress.ERR_NOT_OWNER, "Only the owner can export the questions"), http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"questions-%s.csv\"", room.ID))
+		w.WriteHeader(http.StatusOK)
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"askedAt", "slide", "author", "question", "upvotes", "state"})
+		for _, question := range questions {
+			writer.Write([]string{
+				question.AskedAt.Format(time.RFC3339),
+				strconv.Itoa(question.Slide),
+				question.AuthorName,
+				question.Text,
+				strconv.Itoa(len(question.Upvoters)),
+				string(question.State),
+			})
+		}
+		writer.Flush()
+		return
+	}
+
+	encodedQuestions := make([]map[string]interface{}, 0, len(questions))
+	for _, question := range questions {
+		encodedQuestions = append(encodedQuestions, impress.EncodeQuestion(question))
+	}
+	encoded, _ := json.Marshal(map[string]interface{}{
+		"room":      room.ID,
+		"questions": encodedQuestions,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+// getQuestions returns the questions of the running or of an ended session owned by ownerUUID
+func (room *Room) getQuestions(ownerUUID string) ([]impress.Question, bool) {
+	if room.isSlideShowOwnerUUID(ownerUUID) {
+		return room.getImpressClient().GetQuestions(), true
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	for _, session := range room.finished {
+		if session.ownerUUID == ownerUUID {
+			return session.questions, true
+		}
+	}
+	return nil, false
+}
+
+// archiveLocked keeps the questions of an ended session, dropping the oldest archived session if needed
+func (room *Room) archiveLocked(client *impress.ImpressClient) {
+	session := finishedSession{ownerUUID: client.GetEndedOwnerUUID(), questions: client.GetQuestions(), endedAt: time.Now()}
+	if session.ownerUUID == "" {
+		return
+	}
+	room.finished = append(room.finished, session)
+	if len(room.finished) > MAX_FINISHED_SESSIONS {
+		room.finished = room.finished[len(room.finished)-MAX_FINISHED_SESSIONS:]
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	csv "encoding/csv"
+	http "net/http"
+	testing "testing"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+func TestQuestions(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+	env.impress.UpdateSlide(2)
+
+	owner, _ := env.connect(t, "?ownerUUID="+ownerUUID)
+	readJSON(t, owner)
+	asker, _ := env.connect(t, "?name=Dana")
+	readJSON(t, asker)
+
+	asker.WriteJSON(map[string]string{"command": impress.ASK_QUESTION, "text": "  Why?  "})
+	questions := readCommand(t, owner, impress.QUESTIONS)["questions"].([]interface{})
+	question := questions[0].(map[string]interface{})
+	if question["text"] != "Why?" || question["slide"] != 2.0 || question["authorName"] != "Dana" || question["state"] != "open" {
+		t.Fatalf("unexpected question %v", question)
+	}
+	// Participant IDs are what the owner kicks and bans with, they are not shown to the audience
+	if _, ok := question["authorID"]; ok {
+		t.Errorf("question shows who asked it: %v", question)
+	}
+	questionID := question["questionID"].(string)
+	readCommand(t, asker, impress.QUESTIONS)
+
+	asker.WriteJSON(map[string]string{"command": impress.ASK_QUESTION, "text": "And how?"})
+	if message := readJSON(t, asker); message["error"] != "Only one question can be asked every 10 seconds" {
+		t.Errorf("second question was not rate limited: %v", message)
+	}
+	asker.WriteJSON(map[string]string{"command": impress.UPVOTE_QUESTION, "questionID": questionID})
+	if message := readJSON(t, asker); message["error"] != "Own questions can't be upvoted" {
+		t.Errorf("own question was upvoted: %v", message)
+	}
+
+	owner.WriteJSON(map[string]string{"command": impress.UPVOTE_QUESTION, "questionID": questionID})
+	upvoted := readCommand(t, owner, impress.QUESTIONS)["questions"].([]interface{})
+	if upvoted[0].(map[string]interface{})["upvotes"] != 1.0 {
+		t.Errorf("upvote was not counted: %v", upvoted)
+	}
+	owner.WriteJSON(map[string]string{"command": impress.ANSWER_QUESTION, "questionID": questionID})
+	if open := readCommand(t, owner, impress.QUESTIONS)["questions"].([]interface{}); len(open) != 0 {
+		t.Errorf("answered question is still open: %v", open)
+	}
+
+	env.room.getImpressClient().Terminate()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		response, err := http.Get(env.http.URL + "/questions?format=csv&ownerUUID=" + ownerUUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode == http.StatusOK {
+			records, err := csv.NewReader(response.Body).ReadAll()
+			response.Body.Close()
+			if err != nil || len(records) != 2 || records[1][1] != "2" || records[1][3] != "Why?" || records[1][5] != "answered" {
+				t.Fatalf("unexpected export %v: %v", records, err)
+			}
+			break
+		}
+		response.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatalf("questions of the ended session can't be exported: %d", response.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if response, _ := env.get(t, "/questions?ownerUUID=someone"); response.StatusCode != http.StatusForbidden {
+		t.Errorf("questions were exported without the owner token: %d", response.StatusCode)
+	}
+}
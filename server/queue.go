@@ -0,0 +1,178 @@
+package server
+
+import (
+	json "encoding/json"
+	http "net/http"
+	os "os"
+	filepath "path/filepath"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+// queuedPresentation is an uploaded deck waiting for the running slideshow of its room to end
+type queuedPresentation struct {
+	ownerUUID string
+	fileName  string
+	filePath  string
+	queuedAt  time.Time
+}
+
+func GetQueue(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+
+	ownerUUID := r.URL.Query().Get(OWNER_UUID)
+	queue := room.getQueue()
+	entries := make([]map[string]interface{}, 0, len(queue))
+	toEncode := make(map[string]interface{})
+	for i, queued := range queue {
+		// Owner UUIDs are never listed, since they grant control over the presentation
+		entries = append(entries, map[string]interface{}{
+			"position": i + 1,
+			"fileName": queued.fileName,
+			"queuedAt": queued.queuedAt,
+		})
+		if ownerUUID != "" && queued.ownerUUID == ownerUUID {
+			toEncode["position"] = i + 1
+		}
+	}
+	toEncode["room"] = room.ID
+	toEncode["isRunning"] = room.isSlideShowRunning()
+	toEncode["queue"] = entries
+
+	encoded, _ := json.Marshal(toEncode)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+func CancelQueued(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+
+	ownerUUID := r.URL.Query().Get(OWNER_UUID)
+	queued, ok := room.cancelQueued(ownerUUID)
+	if ownerUUID == "" || !ok {
+		writeError(w, impress.NewError(impress.ERR_NOT_QUEUED, "Presentation is not queued"), http.StatusNotFound)
+		return
+	}
+	removeUpload(queued.filePath)
+	Logger.InfoF("Cancelled queued presentation %s in room %s", queued.fileName, room.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startOrEnqueue starts the presentation right away if the room is free, otherwise it queues it.
+// It returns the queue position, which is 0 for a started presentation
+func (room *Room) startOrEnqueue(queued *queuedPresentation) (int, error) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.isRunningLocked() || len(room.queue) > 0 {
+		room.queue = append(room.queue, queued)
+		Logger.InfoF("Queued presentation %s in room %s at position %d", queued.fileName, room.ID, len(room.queue))
+		room.saveStateLocked()
+		return len(room.queue), nil
+	}
+	return 0, room.startLocked(queued)
+}
+
+// startNext starts the first queued presentation once the given client, which was the room's current one, terminated
+func (room *Room) startNext(previous *impress.ImpressClient) {
+	<-previous.Done()
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	room.archiveLocked(previous)
+	if room.impressClient != previous {
+		return
+	}
+	room.startQueuedLocked()
+	room.saveStateLocked()
+}
+
+// startQueuedLocked starts the first queued presentation that manages to start
+func (room *Room) startQueuedLocked() {
+	for len(room.queue) > 0 {
+		queued := room.queue[0]
+		room.queue = room.queue[1:]
+		if err := room.startLocked(queued); err != nil {
+			Logger.ErrorF("Failed to start queued presentation %s: %v", queued.fileName, err)
+			removeUpload(queued.filePath)
+			continue
+		}
+		return
+	}
+}
+
+func (room *Room) startLocked(queued *queuedPresentation) error {
+	client := impress.NewClient(room.RemoteURL, room.ProfileDirectory)
+	if err := client.StartPresentation(queued.ownerUUID, queued.filePath); err != nil {
+		client.Terminate()
+		return err
+	}
+	Logger.InfoF("Started presentation %s in room %s", queued.fileName, room.ID)
+	room.attachLocked(client)
+	return nil
+}
+
+// attachLocked makes the client the current one of the room and connects it to impress
+func (room *Room) attachLocked(client *impress.ImpressClient) {
+	room.impressClient = client
+	client.SetStateListener(room.saveState)
+	room.saveStateLocked()
+
+	go connectPresentation(client)
+	go room.startNext(client)
+}
+
+func (room *Room) isRunningLocked() bool {
+	return room.impressClient != nil && !room.impressClient.IsTerminated()
+}
+
+func (room *Room) getQueue() []*queuedPresentation {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	return append([]*queuedPresentation{}, room.queue...)
+}
+
+func (room *Room) cancelQueued(ownerUUID string) (*queuedPresentation, bool) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	for i, queued := range room.queue {
+		if queued.ownerUUID == ownerUUID {
+			room.queue = append(room.queue[:i:i], room.queue[i+1:]...)
+			room.saveStateLocked()
+			return queued, true
+		}
+	}
+	return nil, false
+}
+
+// clearQueue drops every queued presentation, so that nothing starts after the current one
+func (room *Room) clearQueue() {
+	room.mu.Lock()
+	queue := room.queue
+	room.queue = nil
+	room.saveStateLocked()
+	room.mu.Unlock()
+
+	for _, queued := range queue {
+		removeUpload(queued.filePath)
+	}
+}
+
+// removeUpload deletes the folder of an uploaded presentation, the same way StopPresentation does
+func removeUpload(filePath string) {
+	if err := os.RemoveAll(filepath.Dir(filePath)); err != nil {
+		Logger.ErrorF("Failed to remove file: %v", err)
+	}
+}
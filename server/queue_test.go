@@ -0,0 +1,76 @@
+package server
+
+import (
+	http "net/http"
+	testing "testing"
+	time "time"
+)
+
+func (env *testEnv) cancel(t *testing.T, ownerUUID string) int {
+	request, _ := http.NewRequest("DELETE", env.http.URL+"/queue?ownerUUID="+ownerUUID, nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("DELETE /queue: %v", err)
+	}
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func TestQueueStartsNextPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	env.startPresentation(t)
+
+	response, body := env.upload(t, "next.ppt", pptContent)
+	if response.StatusCode != http.StatusAccepted {
+		t.Fatalf("upload returned %d: %v", response.StatusCode, body)
+	}
+	nextOwner := body["ownerUUID"].(string)
+
+	_, queue := env.get(t, "/queue?ownerUUID="+nextOwner)
+	entries := queue["queue"].([]interface{})
+	if queue["position"] != 1.0 || len(entries) != 1 {
+		t.Fatalf("unexpected queue %v", queue)
+	}
+	if entry := entries[0].(map[string]interface{}); entry["fileName"] != "next.ppt" || entry["ownerUUID"] != nil {
+		t.Errorf("unexpected queue entry %v", entry)
+	}
+
+	env.room.getImpressClient().Terminate()
+	deadline := time.Now().Add(testTimeout)
+	for env.room.getImpressClient().GetPresentationUUID() != nextOwner {
+		if time.Now().After(deadline) {
+			t.Fatal("queued presentation never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := env.impress.WaitConnected(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if _, queue := env.get(t, "/queue"); len(queue["queue"].([]interface{})) != 0 {
+		t.Errorf("started presentation is still queued: %v", queue)
+	}
+}
+
+func TestCancelQueuedPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+
+	_, body := env.upload(t, "next.ppt", pptContent)
+	nextOwner := body["ownerUUID"].(string)
+
+	if status := env.cancel(t, ownerUUID); status != http.StatusNotFound {
+		t.Errorf("cancelling the running presentation returned %d", status)
+	}
+	if status := env.cancel(t, nextOwner); status != http.StatusNoContent {
+		t.Fatalf("cancel returned %d", status)
+	}
+	if status := env.cancel(t, nextOwner); status != http.StatusNotFound {
+		t.Errorf("second cancel returned %d", status)
+	}
+
+	env.room.getImpressClient().Terminate()
+	time.Sleep(50 * time.Millisecond)
+	if env.room.isSlideShowRunning() {
+		t.Error("cancelled presentation was started")
+	}
+}
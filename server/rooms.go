@@ -0,0 +1,159 @@
+package server
+
+import (
+	json "encoding/json"
+	errors "errors"
+	fmt "fmt"
+	http "net/http"
+	url "net/url"
+	filepath "path/filepath"
+	strings "strings"
+	sync "sync"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+const ROOM_ID = "room"
+
+// Room is a presentation slot with its own LibreOffice instance and Impress remote
+type Room struct {
+	ID               string
+	RemoteURL        string
+	ProfileDirectory string
+	stateDirectory   string
+	impressClient    *impress.ImpressClient
+	queue            []*queuedPresentation
+	finished         []finishedSession
+	mu               sync.Mutex
+}
+
+var rooms = make(map[string]*Room)
+var roomIDs = make([]string, 0)
+var roomsMu sync.Mutex = sync.Mutex{}
+
+// SetupRooms registers a room for every entry, either an ID using remoteURL or "id=url" with its own remote.
+// Impress Remote always listens on port 1599, which LibreOffice doesn't let change, so a remote serves a single
+// LibreOffice and two rooms can't share one
+func SetupRooms(entries []string, remoteURL string, profilesDirectory string) error {
+	roomsByURL := make(map[string]string)
+	for _, entry := range entries {
+		id, roomURL := strings.TrimSpace(entry), remoteURL
+		if separator := strings.Index(entry, "="); separator >= 0 {
+			id, roomURL = strings.TrimSpace(entry[:separator]), strings.TrimSpace(entry[separator+1:])
+		}
+		u, err := url.Parse(roomURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("Room %s has no valid remote URL", id)
+		}
+		if other, ok := roomsByURL[u.Host]; ok {
+			return fmt.Errorf("Rooms %s and %s both use the Impress remote at %s, give one of them its own with %s=url", other, id, u.Host, id)
+		}
+		roomsByURL[u.Host] = id
+
+		profileDirectory := ""
+		if profilesDirectory != "" {
+			profileDirectory = filepath.Join(profilesDirectory, id)
+		}
+		if err := AddRoom(id, roomURL, profileDirectory); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func AddRoom(id string, remoteURL string, profileDirectory string) error {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	if id == "" {
+		return errors.New("Room ID can't be empty")
+	}
+	if _, ok := rooms[id]; ok {
+		return fmt.Errorf("Room %s already exists", id)
+	}
+	rooms[id] = &Room{ID: id, RemoteURL: remoteURL, ProfileDirectory: profileDirectory, stateDirectory: StateDirectory}
+	roomIDs = append(roomIDs, id)
+	Logger.InfoF("Added room %s with impress remote %s", id, remoteURL)
+	return nil
+}
+
+func GetRooms(w http.ResponseWriter, r *http.Request) {
+	response := make([]map[string]interface{}, 0)
+	for _, room := range getRooms() {
+		encoded := map[string]interface{}{
+			"id":        room.ID,
+			"isRunning": room.isSlideShowRunning(),
+			"queued":    len(room.getQueue()),
+		}
+		if room.isSlideShowRunning() {
+			stats := room.getImpressClient().GetStats()
+			encoded["name"] = stats.Name
+			encoded["session"] = stats.Session
+			encoded["controllers"] = stats.Controllers
+			encoded["maxControllers"] = stats.MaxControllers
+		}
+		response = append(response, encoded)
+	}
+
+	encoded, _ := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+// getRoom returns the room selected by the room query parameter, or the first room if there is none.
+// It writes the error response itself if the room doesn't exist
+func getRoom(w http.ResponseWriter, r *http.Request) (*Room, bool) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	id := r.URL.Query().Get(ROOM_ID)
+	if id == "" && len(roomIDs) > 0 {
+		id = roomIDs[0]
+	}
+	room, ok := rooms[id]
+	if !ok {
+		writeError(w, impress.NewError(impress.ERR_ROOM_NOT_FOUND, "Room not found"), http.StatusNotFound)
+		return nil, false
+	}
+	return room, true
+}
+
+func getRooms() []*Room {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+
+	list := make([]*Room, 0, len(roomIDs))
+	for _, id := range roomIDs {
+		list = append(list, rooms[id])
+	}
+	return list
+}
+
+func (room *Room) getImpressClient() *impress.ImpressClient {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	return room.impressClient
+}
+
+func (room *Room) setImpressClient(impr *impress.ImpressClient) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	room.impressClient = impr
+}
+
+func (room *Room) isSlideShowRunning() bool {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	return room.isRunningLocked()
+}
+
+func (room *Room) isSlideShowOwnerUUID(uuid string) bool {
+	if room.isSlideShowRunning() {
+		return room.getImpressClient().GetPresentationUUID() == uuid
+	}
+	return false
+}
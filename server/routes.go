@@ -0,0 +1,29 @@
+package server
+
+import (
+	mux "github.com/gorilla/mux"
+)
+
+// Prefix of the versioned API, which is described by the OpenAPI document
+const API_PREFIX = "/api/v1"
+
+// RegisterRoutes mounts the JSON endpoints on the router, which is the root one for the unversioned paths
+// or a subrouter for API_PREFIX. Every route has to be described in openapi.json
+func RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/rooms", GetRooms).Methods("GET")
+	r.HandleFunc("/stats", GetStats).Methods("GET")
+	r.HandleFunc("/upload", UploadPPT).Methods("POST")
+	r.HandleFunc("/queue", GetQueue).Methods("GET")
+	r.HandleFunc("/queue", CancelQueued).Methods("DELETE")
+	r.HandleFunc("/pairing", GetPairing).Methods("GET")
+	r.HandleFunc("/slides", GetSlides).Methods("GET")
+	r.HandleFunc("/slides/{index}/preview", GetSlidePreview).Methods("GET")
+	r.HandleFunc("/questions", ExportQuestions).Methods("GET")
+	r.HandleFunc("/control", ServeImpressController).Methods("GET")
+	r.HandleFunc("/events", StreamEvents).Methods("GET")
+	r.HandleFunc("/presentation", StopPresentation).Methods("DELETE")
+	r.HandleFunc("/presentation/slides", GetPresentationSlides).Methods("GET")
+	r.HandleFunc("/presentation/goto/{index}", ControlPresentation).Methods("POST")
+	r.HandleFunc("/presentation/{action}", ControlPresentation).Methods("POST")
+	r.HandleFunc("/openapi.json", GetOpenAPI).Methods("GET")
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	sha1 "crypto/sha1"
+	base64 "encoding/base64"
+	hex "encoding/hex"
+	json "encoding/json"
+	fmt "fmt"
+	http "net/http"
+	url "net/url"
+	strconv "strconv"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	mux "github.com/gorilla/mux"
+)
+
+func GetSlides(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusNotFound)
+		return
+	}
+
+	client := room.getImpressClient()
+	slides := make([]map[string]interface{}, 0)
+	for _, slide := range client.GetPreviewSlides() {
+		slides = append(slides, map[string]interface{}{
+			"index":   slide,
+			"preview": previewURL(room.ID, slide),
+		})
+	}
+
+	toEncode := map[string]interface{}{
+		"totalSlides": client.GetStats().Status.TotalSlides,
+		"slides":      slides,
+	}
+	encoded, _ := json.Marshal(toEncode)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+func GetSlidePreview(w http.ResponseWriter, r *http.Request) {
+	room, ok := getRoom(w, r)
+	if !ok {
+		return
+	}
+	if !room.isSlideShowRunning() {
+		writeError(w, impress.ErrNotRunning, http.StatusNotFound)
+		return
+	}
+
+	slide, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil || slide < 0 {
+		writeError(w, impress.NewError(impress.ERR_INVALID_INDEX, "index value not a number or less than 0").WithDetail("field", "index"), http.StatusBadRequest)
+		return
+	}
+	preview, ok := room.getImpressClient().GetPreview(slide)
+	if !ok {
+		writeError(w, impress.NewError(impress.ERR_PREVIEW_NOT_FOUND, "Slide preview not available"), http.StatusNotFound)
+		return
+	}
+
+	hash := sha1.Sum([]byte(preview))
+	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(hash[:]))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	image, err := base64.StdEncoding.DecodeString(preview)
+	if err != nil {
+		Logger.ErrorF("Failed to decode preview of slide %d: %v", slide, err)
+		writeError(w, impress.NewError(impress.ERR_INTERNAL, "Failed to decode slide preview"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// previewURL links to the versioned route, the one described by the OpenAPI document
+func previewURL(roomID string, slide int) string {
+	return fmt.Sprintf("%s/slides/%d/preview?%s=%s", API_PREFIX, slide, ROOM_ID, url.QueryEscape(roomID))
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	bytes "bytes"
+	base64 "encoding/base64"
+	ioutil "io/ioutil"
+	http "net/http"
+	testing "testing"
+	time "time"
+)
+
+var pngContent = []byte("\x89PNG\r\n\x1a\nfake image data")
+
+func waitForPreview(t *testing.T, room *Room, slide int) {
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		if _, ok := room.getImpressClient().GetPreview(slide); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("preview of slide %d never arrived", slide)
+}
+
+func TestGetSlidePreview(t *testing.T) {
+	env := newTestEnv(t)
+	env.startPresentation(t)
+	env.impress.SendPreview(2, base64.StdEncoding.EncodeToString(pngContent))
+	waitForPreview(t, env.room, 2)
+
+	response, err := http.Get(env.http.URL + "/slides/2/preview")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || !bytes.Equal(body, pngContent) {
+		t.Fatalf("GET preview returned %d with %q", response.StatusCode, body)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "image/png" {
+		t.Errorf("Content-Type is %s", contentType)
+	}
+	etag := response.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag header")
+	}
+
+	request, _ := http.NewRequest("GET", env.http.URL+"/slides/2/preview", nil)
+	request.Header.Set("If-None-Match", etag)
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional GET returned %d, expected %d", response.StatusCode, http.StatusNotModified)
+	}
+
+	if response, _ := env.get(t, "/slides/1/preview"); response.StatusCode != http.StatusNotFound {
+		t.Errorf("missing preview returned %d, expected %d", response.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestGetSlides(t *testing.T) {
+	env := newTestEnv(t)
+	env.startPresentation(t)
+	env.impress.SendPreview(2, base64.StdEncoding.EncodeToString(pngContent))
+	env.impress.SendPreview(0, base64.StdEncoding.EncodeToString(pngContent))
+	waitForPreview(t, env.room, 0)
+
+	_, body := env.get(t, "/slides")
+	if body["totalSlides"] != 3.0 {
+		t.Errorf("totalSlides is %v", body["totalSlides"])
+	}
+	slides := body["slides"].([]interface{})
+	if len(slides) != 2 {
+		t.Fatalf("listed %d slides, expected 2", len(slides))
+	}
+	first := slides[0].(map[string]interface{})
+	if first["index"] != 0.0 || first["preview"] != API_PREFIX+"/slides/0/preview?room=default" {
+		t.Errorf("unexpected first slide %v", first)
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	json "encoding/json"
+	ioutil "io/ioutil"
+	os "os"
+	filepath "path/filepath"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+)
+
+const DEFAULT_STATE_DIRECTORY = "state"
+
+// StateDirectory has to be set before the rooms are added
+var StateDirectory string = DEFAULT_STATE_DIRECTORY
+
+// roomState is persisted for every room, so that a restarted backend resumes where it stopped
+type roomState struct {
+	RemoteURL        string
+	ProfileDirectory string
+	Session          *impress.SessionSnapshot
+	Queue            []queuedState
+}
+
+type queuedState struct {
+	OwnerUUID string
+	FileName  string
+	FilePath  string
+	QueuedAt  time.Time
+}
+
+// RestoreRooms resumes the presentations persisted for the rooms. Presentations whose LibreOffice process
+// is gone are cleaned up, and the next queued one is started in their place
+func RestoreRooms() {
+	for _, room := range getRooms() {
+		if err := room.restoreState(); err != nil {
+			Logger.ErrorF("Failed to restore room %s: %v", room.ID, err)
+		}
+	}
+}
+
+func (room *Room) restoreState() error {
+	encoded, err := ioutil.ReadFile(room.statePath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var state roomState
+	if err := json.Unmarshal(encoded, &state); err != nil {
+		os.Remove(room.statePath())
+		return err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	for _, queued := range state.Queue {
+		room.queue = append(room.queue, &queuedPresentation{ownerUUID: queued.OwnerUUID, fileName: queued.FileName, filePath: queued.FilePath, queuedAt: queued.QueuedAt})
+	}
+	if state.Session != nil {
+		client, err := impress.RestoreClient(state.RemoteURL, state.ProfileDirectory, *state.Session)
+		if err != nil {
+			Logger.WarningF("Can't resume the presentation of room %s, cleaning it up: %v", room.ID, err)
+			impress.DiscardSnapshot(*state.Session)
+		} else {
+			Logger.InfoF("Resuming the presentation of room %s", room.ID)
+			room.attachLocked(client)
+		}
+	}
+	if !room.isRunningLocked() {
+		room.startQueuedLocked()
+	}
+	room.saveStateLocked()
+	return nil
+}
+
+func (room *Room) saveState() {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	room.saveStateLocked()
+}
+
+// saveStateLocked writes the state of the room, or removes it once there is nothing to resume
+func (room *Room) saveStateLocked() {
+	state := roomState{RemoteURL: room.RemoteURL, ProfileDirectory: room.ProfileDirectory, Queue: make([]queuedState, 0)}
+	if room.impressClient != nil {
+		if snapshot, ok := room.impressClient.Snapshot(); ok {
+			state.Session = &snapshot
+		}
+	}
+	for _, queued := range room.queue {
+		state.Queue = append(state.Queue, queuedState{OwnerUUID: queued.ownerUUID, FileName: queued.fileName, FilePath: queued.filePath, QueuedAt: queued.queuedAt})
+	}
+
+	path := room.statePath()
+	if state.Session == nil && len(state.Queue) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			Logger.ErrorF("Failed to remove state of room %s: %v", room.ID, err)
+		}
+		return
+	}
+
+	encoded, _ := json.Marshal(state)
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	// Written aside and renamed, so a crash never leaves a truncated state behind
+	if err := ioutil.WriteFile(path+".tmp", encoded, 0600); err != nil {
+		Logger.ErrorF("Failed to save state of room %s: %v", room.ID, err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		Logger.ErrorF("Failed to save state of room %s: %v", room.ID, err)
+	}
+}
+
+func (room *Room) statePath() string {
+	return filepath.Join(filepath.Dir(os.Args[0]), room.stateDirectory, room.ID+".json")
+}
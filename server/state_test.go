@@ -0,0 +1,168 @@
+package server
+
+import (
+	json "encoding/json"
+	ioutil "io/ioutil"
+	os "os"
+	filepath "path/filepath"
+	syscall "syscall"
+	testing "testing"
+	time "time"
+
+	impress "github.com/DanInci/raspi-projector-backend/impress"
+	impresstest "github.com/DanInci/raspi-projector-backend/impress/impresstest"
+)
+
+func readState(t *testing.T, room *Room) (roomState, bool) {
+	var state roomState
+	encoded, err := ioutil.ReadFile(room.statePath())
+	if os.IsNotExist(err) {
+		return state, false
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(encoded, &state); err != nil {
+		t.Fatal(err)
+	}
+	return state, true
+}
+
+func writeState(t *testing.T, room *Room, state roomState) {
+	encoded, _ := json.Marshal(state)
+	os.MkdirAll(filepath.Dir(room.statePath()), os.ModePerm)
+	if err := ioutil.WriteFile(room.statePath(), encoded, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// leftoverPresentation uploads a file the way UploadPPT does and starts a fake soffice for it
+func leftoverPresentation(t *testing.T, name string) (string, *os.Process) {
+	folder := filepath.Join(filepath.Dir(os.Args[0]), UploadDirectory, "default", name)
+	os.MkdirAll(folder, os.ModePerm)
+	filePath := filepath.Join(folder, "deck.ppt")
+	if err := ioutil.WriteFile(filePath, pptContent, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := impresstest.FakeOffice("soffice", "", filePath)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cmd.Process.Kill() })
+	return filePath, cmd.Process
+}
+
+func TestStateFollowsPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	ownerUUID := env.startPresentation(t)
+	env.impress.UpdateSlide(2)
+
+	deadline := time.Now().Add(testTimeout)
+	for {
+		state, ok := readState(t, env.room)
+		if ok && state.Session != nil && state.Session.Status.CurrentSlide == 2 {
+			if state.Session.OwnerUUID != ownerUUID || state.Session.PID == 0 {
+				t.Fatalf("unexpected saved session %+v", state.Session)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("current slide was never saved: %+v", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	env.room.getImpressClient().Terminate()
+	for {
+		if _, ok := readState(t, env.room); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("state of the terminated presentation was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRestoreResumesPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	filePath, process := leftoverPresentation(t, "leftover")
+	identity, _ := impress.ProcessIdentity(process.Pid)
+	writeState(t, env.room, roomState{
+		RemoteURL: env.impress.URL,
+		Session: &impress.SessionSnapshot{
+			OwnerUUID:       "owner-token",
+			FilePath:        filePath,
+			PID:             process.Pid,
+			ProcessIdentity: identity,
+			Status:          impress.SlideShowStatus{State: impress.STATE_RUNNING, TotalSlides: 5, CurrentSlide: 3},
+			Invites:         map[string]impress.Role{"invite-token": impress.ROLE_CO_PRESENTER},
+		},
+	})
+
+	RestoreRooms()
+	if err := env.impress.WaitConnected(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	request, err := env.impress.NextRequest(testTimeout)
+	if err != nil || request != (impress.GoToSlide{Index: 3}) {
+		t.Fatalf("current slide was not restored: %v %v", request, err)
+	}
+
+	client := env.room.getImpressClient()
+	if !env.room.isSlideShowOwnerUUID("owner-token") {
+		t.Error("owner token was not restored")
+	}
+	if role, ok := client.GetInviteRole("invite-token"); !ok || role != impress.ROLE_CO_PRESENTER {
+		t.Error("invites were not restored")
+	}
+}
+
+func TestRestoreLeavesReusedPIDAlone(t *testing.T) {
+	if _, err := impress.ProcessIdentity(os.Getpid()); err != nil {
+		t.Skip("process identities can't be read on this system")
+	}
+	env := newTestEnv(t)
+	filePath, process := leftoverPresentation(t, "reused")
+	// The PID is alive, but belongs to another process than the saved one, like after a reboot
+	writeState(t, env.room, roomState{
+		RemoteURL: env.impress.URL,
+		Session:   &impress.SessionSnapshot{OwnerUUID: "old-owner", FilePath: filePath, PID: process.Pid, ProcessIdentity: "other-boot:1"},
+	})
+
+	RestoreRooms()
+	if env.room.isSlideShowOwnerUUID("old-owner") {
+		t.Error("presentation was restored on an unrelated process")
+	}
+	if err := process.Signal(syscall.Signal(0)); err != nil {
+		t.Errorf("unrelated process was stopped: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(filePath)); !os.IsNotExist(err) {
+		t.Errorf("upload of the lost presentation was kept: %v", err)
+	}
+}
+
+func TestRestoreCleansUpDeadPresentation(t *testing.T) {
+	env := newTestEnv(t)
+	deadPath, deadProcess := leftoverPresentation(t, "dead")
+	deadProcess.Kill()
+	deadProcess.Wait()
+	queuedPath, queuedProcess := leftoverPresentation(t, "queued")
+	queuedProcess.Kill()
+
+	writeState(t, env.room, roomState{
+		RemoteURL: env.impress.URL,
+		Session:   &impress.SessionSnapshot{OwnerUUID: "dead-owner", FilePath: deadPath, PID: deadProcess.Pid},
+		Queue:     []queuedState{{OwnerUUID: "queued-owner", FileName: "deck.ppt", FilePath: queuedPath, QueuedAt: time.Now()}},
+	})
+
+	RestoreRooms()
+	if _, err := os.Stat(filepath.Dir(deadPath)); !os.IsNotExist(err) {
+		t.Errorf("upload of the dead presentation was kept: %v", err)
+	}
+	if !env.room.isSlideShowOwnerUUID("queued-owner") {
+		t.Fatal("queued presentation was not started in place of the dead one")
+	}
+	if state, ok := readState(t, env.room); !ok || state.Session == nil || state.Session.OwnerUUID != "queued-owner" || len(state.Queue) != 0 {
+		t.Errorf("unexpected state after cleanup %+v", state)
+	}
+}
@@ -4,55 +4,36 @@ import (
 	json "encoding/json"
 	errors "errors"
 	http "net/http"
-	strconv "strconv"
 
 	impress "github.com/DanInci/raspi-projector-backend/impress"
 	betterguid "github.com/kjk/betterguid"
 )
 
-func isSlideShowOwnerUUID(uuid string) bool {
-	if isSlideShowRunning() {
-		return getImpressClient().GetPresentationUUID() == uuid
-	}
-	return false
-}
-
-func isSlideShowRunning() bool {
-	client := getImpressClient()
-	return client != nil && !client.IsTerminated()
-}
-
 func generateUUID() string {
 	return betterguid.New()
 }
 
-func encodeImpressStats(impressStats *impress.ImpressStats) ([]byte, error) {
-	statusEncoding := make(map[string]interface{})
-
-	if len(impressStats.Status) > 0 {
-		statusEncoding["command"] = impressStats.Status[0]
-		switch impressStats.Status[0] {
-		case impress.SLIDE_SHOW_FINISHED:
-		case impress.SLIDE_SHOW_STARTED:
-			totalSlides, _ := strconv.Atoi(impressStats.Status[1])
-			currentSlide, _ := strconv.Atoi(impressStats.Status[2])
-			statusEncoding["totalSlides"] = totalSlides
-			statusEncoding["currentSlide"] = currentSlide
-		case impress.SLIDE_UPDATED:
-			currentSlide, _ := strconv.Atoi(impressStats.Status[1])
-			statusEncoding["currentSlide"] = currentSlide
-		default:
-			return nil, errors.New("Failed to encode command")
-		}
+func encodeImpressStats(roomID string, impressStats *impress.ImpressStats) ([]byte, error) {
+	statusEncoding, err := encodeSlideShowStatus(impressStats.Status)
+	if err != nil {
+		return nil, err
 	}
 
 	response := map[string]interface{}{
-		"name":           impressStats.Name,
-		"status":         statusEncoding,
+		"room":    roomID,
+		"name":    impressStats.Name,
+		"session": impressStats.Session,
+		"status":  statusEncoding,
+		"server": map[string]interface{}{
+			"version":      impressStats.Server.Version,
+			"capabilities": impressStats.Server.Capabilities,
+		},
 		"controllers":    impressStats.Controllers,
 		"maxControllers": impressStats.MaxControllers,
 		"isOwnerPresent": impressStats.IsOwnerPresent,
 		"ownerTimeout":   impressStats.OwnerTimeout,
+		"roles":          impressStats.Roles,
+		"subscribers":    impressStats.Subscribers,
 	}
 
 	encoded, err := json.Marshal(response)
@@ -62,11 +43,29 @@ func encodeImpressStats(impressStats *impress.ImpressStats) ([]byte, error) {
 	return encoded, nil
 }
 
-func writeError(w http.ResponseWriter, message string, status int) {
-	toEncode := map[string]string{
-		"error": message,
+func encodeSlideShowStatus(status impress.SlideShowStatus) (map[string]interface{}, error) {
+	statusEncoding := make(map[string]interface{})
+	statusEncoding["state"] = status.State
+
+	if message := status.Message(); message != nil {
+		statusEncoding["command"] = message.Command()
+		switch message := message.(type) {
+		case impress.SlideShowFinished:
+		case impress.SlideShowStarted:
+			statusEncoding["totalSlides"] = message.Total
+			statusEncoding["currentSlide"] = message.Current
+		case impress.SlideUpdated:
+			statusEncoding["currentSlide"] = message.Current
+		default:
+			return nil, errors.New("Failed to encode command")
+		}
 	}
-	encoded, _ := json.Marshal(toEncode)
+	return statusEncoding, nil
+}
+
+// writeError answers with the same error body as the websocket, errors without a code are internal ones
+func writeError(w http.ResponseWriter, err error, status int) {
+	encoded, _ := json.Marshal(impress.EncodeError(err))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	w.Write(encoded)